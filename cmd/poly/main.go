@@ -3,9 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
 	"log"
 	"os"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,43 @@ var (
 	concurrency  int
 	logFrequency int
 	cpuprofile   string
+	schedule     string
+	temperature0 float64
+	temperature1 float64
+	population   int
+	selection    string
+	crossover    string
+	elitism      int
+	evenOdd      bool
+	metric       string
+	reportMetric string
+	maskPath     string
+	importance   string
+	levels       string
+	outputSize   string
+	antialias    bool
+	mutations    string
+	minOrder     int
+	maxOrder     int
+	translate    float64
+	scale        float64
+	rotate       float64
+	minStrength  float64
+	colorMode    string
+	colorSigma   float64
+	shapeOnly    bool
+	alphaMin     int
+	alphaMax     int
+	alpha        int
+	opaque       bool
+	greedy       int
+	restarts     int
+	threshold    float64
+	reseed       int
+	start        int
+	growEvery    int
+	growPatience int
+	initKind     string
 )
 
 type flagArray []string
@@ -43,17 +82,142 @@ func init() {
 	flag.IntVar(&polygonCount, "p", 50, "number of polygons")
 	flag.IntVar(&iterations, "n", 1000, "number of iterations")
 	flag.IntVar(&maxImageSize, "r", 256, "resize large input images to this size")
-	flag.IntVar(&concurrency, "c", 3, "number of workers to use")
+	flag.IntVar(&concurrency, "c", 3, "number of candidates evaluated in parallel per iteration")
 	flag.IntVar(&logFrequency, "l", 1000, "frequency of logs in number of iterations")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	flag.StringVar(&schedule, "anneal", "", "simulated annealing schedule: linear, exponential or adaptive")
+	flag.Float64Var(&temperature0, "t0", 0.01, "initial annealing temperature, relative to the score")
+	flag.Float64Var(&temperature1, "t1", 0.0001, "final annealing temperature, relative to the score")
+	flag.IntVar(&population, "population", 0, "population size of the genetic algorithm, 0 uses a single individual")
+	flag.StringVar(&selection, "selection", "tournament", "genetic algorithm selection: tournament or roulette")
+	flag.StringVar(&crossover, "crossover", "uniform", "genetic algorithm crossover: uniform, one-point or per-polygon")
+	flag.IntVar(&elitism, "elitism", 1, "number of best individuals kept unchanged every generation")
+	flag.StringVar(&mutations, "mutations", "", "comma separated mutation weights of vertex, color, insert, delete, up, down, swap, front, back, translate, scale and rotate, such as insert=0.2,swap=0.1")
+	flag.IntVar(&minOrder, "min-order", 3, "minimum number of vertices of a polygon")
+	flag.IntVar(&maxOrder, "max-order", 5, "maximum number of vertices of a polygon")
+	flag.Float64Var(&translate, "translate-strength", 10, "largest displacement in pixels of the translate mutation")
+	flag.Float64Var(&scale, "scale-strength", 0.2, "largest relative change of size of the scale mutation")
+	flag.Float64Var(&rotate, "rotate-strength", 0.3, "largest angle in radians of the rotate mutation")
+	flag.Float64Var(&minStrength, "min-strength", 0.05, "fraction the mutation strengths can shrink to as the run converges")
+	flag.StringVar(&colorMode, "color-mutation", "random", "color mutation: random, uniform or gaussian")
+	flag.Float64Var(&colorSigma, "color-sigma", 20, "initial standard deviation of the gaussian color mutation")
+	flag.BoolVar(&shapeOnly, "shape-only", false, "only search shapes, colors are fitted to the target")
+	flag.IntVar(&alphaMin, "alpha-min", 0, "minimum alpha of the polygons")
+	flag.IntVar(&alphaMax, "alpha-max", 255, "maximum alpha of the polygons")
+	flag.IntVar(&alpha, "alpha", 0, "fixed alpha of every polygon, exclusive with alpha-min and alpha-max")
+	flag.BoolVar(&opaque, "opaque", false, "make every polygon opaque")
+	flag.IntVar(&greedy, "greedy", 0, "add polygons one at a time with this number of iterations each, replaces -n")
+	flag.IntVar(&restarts, "restarts", 20, "number of random polygons tried before improving each greedy polygon")
+	flag.Float64Var(&threshold, "threshold", 0, "prune: largest increase of the score caused by removing a polygon")
+	flag.IntVar(&reseed, "reseed", 0, "prune: add polygons back up to -p with this number of iterations each")
+	flag.StringVar(&initKind, "init", "random", "initialization of the polygons of image inputs: random, error or delaunay")
+	flag.IntVar(&start, "start", 0, "initial number of polygons, growing up to -p during the run")
+	flag.IntVar(&growEvery, "grow-every", 0, "add a polygon every this number of iterations")
+	flag.IntVar(&growPatience, "grow-patience", 500, "add a polygon after this number of iterations without improvement")
+	flag.BoolVar(&antialias, "aa", false, "anti-alias polygons during the optimization, outputs are always anti-aliased, GOB inputs keep theirs unless set")
+	flag.BoolVar(&evenOdd, "evenodd", false, "fill polygons with the even-odd rule instead of non-zero, GOB inputs keep theirs unless set")
+	flag.StringVar(&metric, "metric", "mse", "fitness metric: mse, rmse, psnr, mae, block-ssim, block-ms-ssim, cie76 or ciede2000, GOB inputs keep their metric unless set")
+	flag.StringVar(&reportMetric, "report", "", "additional metric logged next to the score")
+	flag.StringVar(&maskPath, "mask", "", "grayscale image weighting the importance of every pixel")
+	flag.StringVar(&importance, "importance", "", "compute the mask from the input image: sobel, variance or saliency")
+	flag.StringVar(&outputSize, "out-size", "", "size of PNG and SVG outputs as WIDTHxHEIGHT or the largest side, defaults to the input size")
+	flag.StringVar(&levels, "levels", "", "comma separated iterations per pyramid level, from the coarsest to the full size, replaces -n")
+}
+
+// isFlagSet tells whether the flag was given in the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// isAnyFlagSet tells whether any of the flags was given in the command line.
+func isAnyFlagSet(names ...string) bool {
+	for _, name := range names {
+		if isFlagSet(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadImage loads an image and scales it down if needed. It also returns the
+// size of the image before scaling it.
+func loadImage(path string) (image.Image, image.Point, error) {
+	img, err := poly.LoadImage(path)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	original := img.Bounds().Size()
+	size := uint(maxImageSize)
+	if size > 0 {
+		img = resize.Thumbnail(size, size, img, resize.Bilinear)
+	}
+	return img, original, nil
+}
+
+// parseSize parses an output size given as WIDTHxHEIGHT, or as the largest
+// side keeping the aspect ratio of width and height.
+func parseSize(size string, width, height int) (int, int, error) {
+	if w, h, found := strings.Cut(size, "x"); found {
+		ow, err := strconv.Atoi(w)
+		if err != nil {
+			return 0, 0, err
+		}
+		oh, err := strconv.Atoi(h)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ow <= 0 || oh <= 0 {
+			return 0, 0, fmt.Errorf("output size should be > 0")
+		}
+		return ow, oh, nil
+	}
+	side, err := strconv.Atoi(size)
+	if err != nil {
+		return 0, 0, err
+	}
+	if side <= 0 {
+		return 0, 0, fmt.Errorf("output size should be > 0")
+	}
+	if width >= height {
+		return side, (side*height + width/2) / width, nil
+	}
+	return (side*width + height/2) / height, side, nil
 }
 
 func main() {
-	flag.Parse()
+	// poly prune removes the useless polygons of a GOB model instead of
+	// optimizing it
+	prune := len(os.Args) > 1 && os.Args[1] == "prune"
+	if prune {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 	// flag validation
 	if len(inputPath) == 0 {
 		poly.PrintDefaultsWithError("input argument required")
 	}
+	if prune && strings.ToLower(filepath.Ext(inputPath)) != ".gob" {
+		poly.PrintDefaultsWithError("prune requires a GOB input")
+	}
+	if len(maskPath) > 0 && len(importance) > 0 {
+		poly.PrintDefaultsWithError("mask and importance arguments are exclusive")
+	}
+	alphaModes := 0
+	for _, set := range []bool{isFlagSet("alpha"), isFlagSet("opaque"), isAnyFlagSet("alpha-min", "alpha-max")} {
+		if set {
+			alphaModes++
+		}
+	}
+	if alphaModes > 1 {
+		poly.PrintDefaultsWithError("alpha, opaque and alpha range arguments are exclusive")
+	}
 	if len(Outputs) == 0 {
 		poly.PrintDefaultsWithError("output argument required")
 	}
@@ -63,6 +227,42 @@ func main() {
 	if iterations <= 0 {
 		poly.PrintDefaultsWithError("number of iterations should be > 0")
 	}
+	if start < 0 || growEvery < 0 || growPatience < 0 {
+		poly.PrintDefaultsWithError("start and growth arguments should be >= 0")
+	}
+	if schedule != "" && population > 0 {
+		poly.PrintDefaultsWithError("anneal and population arguments are exclusive")
+	}
+	if isFlagSet("init") && strings.ToLower(filepath.Ext(inputPath)) == ".gob" {
+		poly.PrintDefaultsWithError("init requires an image input")
+	}
+	if start > 0 && initKind == string(poly.DelaunayInit) {
+		poly.PrintDefaultsWithError("start and delaunay initialization are exclusive, the triangles must cover the image")
+	}
+	if greedy > 0 && initKind != string(poly.RandomInit) {
+		poly.PrintDefaultsWithError("init and greedy arguments are exclusive")
+	}
+	if len(levels) > 0 && greedy > 0 {
+		poly.PrintDefaultsWithError("levels and greedy arguments are exclusive")
+	}
+	if greedy > 0 {
+		iterations = greedy * polygonCount
+	}
+	if prune {
+		iterations = reseed * polygonCount
+	}
+	var pyramid []int
+	if len(levels) > 0 {
+		iterations = 0
+		for _, level := range strings.Split(levels, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(level))
+			if err != nil || n < 0 {
+				poly.PrintDefaultsWithError("invalid pyramid level: " + level)
+			}
+			pyramid = append(pyramid, n)
+			iterations += n
+		}
+	}
 
 	if cpuprofile != "" {
 		f, err := os.Create(cpuprofile)
@@ -84,18 +284,12 @@ func main() {
 			return
 		}
 	} else {
-		inputImage, err := poly.LoadImage(inputPath)
+		inputImage, original, err := loadImage(inputPath)
 		if err != nil {
 			log.Printf("unable to load image: %v", err)
 			return
 		}
 
-		// scale down input image if needed
-		size := uint(maxImageSize)
-		if size > 0 {
-			inputImage = resize.Thumbnail(size, size, inputImage, resize.Bilinear)
-		}
-
 		// Main block
 		whiteColor := poly.Color{
 			R: 255,
@@ -105,10 +299,194 @@ func main() {
 		}
 		randomSeed := time.Now().UTC().UnixNano()
 		model = poly.NewModel(inputImage, polygonCount, randomSeed, whiteColor)
+		model.OriginalWidth, model.OriginalHeight = original.X, original.Y
+	}
+	// GOB inputs keep their rendering settings unless the flags are set
+	if extension != ".gob" || isFlagSet("evenodd") {
+		model.FillRule = poly.NonZero
+		if evenOdd {
+			model.FillRule = poly.EvenOdd
+		}
+	}
+	if extension != ".gob" || isFlagSet("aa") {
+		model.AntiAlias = antialias
+	}
+	model.Rescore()
+
+	if maskPath != "" {
+		mask, _, err := loadImage(maskPath)
+		if err != nil {
+			log.Printf("unable to load mask: %v", err)
+			return
+		}
+		err = model.SetMask(mask)
+		if err != nil {
+			log.Printf("unable to set mask: %v", err)
+			return
+		}
+	}
+	if importance != "" {
+		err := model.SetImportance(poly.ImportanceKind(importance))
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+	}
+	if extension != ".gob" || isFlagSet("metric") {
+		err := model.SetMetric(poly.MetricKind(metric))
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+	}
+	if reportMetric != "" {
+		err := model.SetReportMetric(poly.MetricKind(reportMetric))
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+	}
+	if schedule != "" {
+		s, err := poly.NewSchedule(poly.ScheduleKind(schedule), temperature0, temperature1)
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		model.Annealing = s
+	}
+	if population > 0 {
+		p, err := poly.NewPopulation(population, poly.SelectionKind(selection), poly.CrossoverKind(crossover))
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		p.Elitism = elitism
+		err = p.Validate()
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		model.Population = p
+	}
+	var alphaRange *poly.AlphaRange
+	switch {
+	case opaque:
+		alphaRange = poly.OpaqueAlpha()
+	case isFlagSet("alpha"):
+		if alpha < 0 || alpha > 255 {
+			poly.PrintDefaultsWithError("alpha should be in [0, 255]")
+		}
+		alphaRange = poly.FixedAlpha(uint8(alpha))
+	case isAnyFlagSet("alpha-min", "alpha-max"):
+		r, err := poly.NewAlphaRange(alphaMin, alphaMax)
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		alphaRange = r
+	}
+	if alphaRange != nil {
+		if extension == ".gob" {
+			model.SetAlpha(alphaRange)
+		} else {
+			// the polygons of image inputs are created with it below
+			model.Alpha = alphaRange
+		}
+	}
+	mutationFlags := []string{
+		"mutations", "min-order", "max-order",
+		"translate-strength", "scale-strength", "rotate-strength", "min-strength",
+		"color-mutation", "color-sigma", "shape-only",
+	}
+	if isAnyFlagSet(mutationFlags...) {
+		m := poly.DefaultMutations()
+		if model.Mutations != nil {
+			*m = *model.Mutations
+		}
+		if isFlagSet("min-order") {
+			m.MinOrder = minOrder
+		}
+		if isFlagSet("max-order") {
+			m.MaxOrder = maxOrder
+		}
+		if isFlagSet("translate-strength") {
+			m.TranslateStrength = translate
+		}
+		if isFlagSet("scale-strength") {
+			m.ScaleStrength = scale
+		}
+		if isFlagSet("rotate-strength") {
+			m.RotateStrength = rotate
+		}
+		if isFlagSet("min-strength") {
+			m.MinStrength = minStrength
+		}
+		if isFlagSet("color-mutation") {
+			m.ColorMutation = poly.ColorMutationKind(colorMode)
+		}
+		if isFlagSet("color-sigma") {
+			m.ColorSigma = colorSigma
+		}
+		if isFlagSet("shape-only") {
+			m.ShapeOnly = shapeOnly
+		}
+		err := m.Validate()
+		if err == nil && mutations != "" {
+			err = m.SetWeights(mutations)
+		}
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		model.Mutations = m
+	}
+	if extension != ".gob" {
+		// the polygons are created once the mask, the metric, the alpha and
+		// the order bounds are known
+		err := model.Initialize(poly.InitKind(initKind))
+		if err != nil {
+			poly.PrintDefaultsWithError(err.Error())
+		}
+		if start > 0 && start < polygonCount {
+			model.Polygons = model.Polygons[:start]
+			model.Rescore()
+		}
+	}
+	if isAnyFlagSet("start", "grow-every", "grow-patience") {
+		model.Growth = &poly.Growth{Every: growEvery, Patience: growPatience}
+		if isFlagSet("p") {
+			model.NumPolygons = polygonCount
+		}
+	}
+
+	err := model.Validate()
+	if err != nil {
+		poly.PrintDefaultsWithError(err.Error())
+	}
+	if n := poly.PyramidLevels(model.Width, model.Height); len(pyramid) > n {
+		poly.PrintDefaultsWithError(fmt.Sprintf("a %vx%v image allows at most %v pyramid levels", model.Width, model.Height, n))
 	}
 
 	start := time.Now()
-	score := model.Optimize(iterations, concurrency, logFrequency)
+	var score float64
+	switch {
+	case prune:
+		removed := model.Prune(threshold)
+		fmt.Printf("pruned %d polygons, %d left\n", removed, len(model.Polygons))
+		if isFlagSet("p") {
+			model.NumPolygons = polygonCount
+		}
+		score = model.Score
+		if reseed > 0 {
+			score = model.OptimizeGreedy(restarts, reseed, concurrency, logFrequency)
+		}
+	case greedy > 0:
+		if extension != ".gob" {
+			// start from the background
+			model.Polygons = nil
+			model.Rescore()
+		}
+		if isFlagSet("p") {
+			model.NumPolygons = polygonCount
+		}
+		score = model.OptimizeGreedy(restarts, greedy, concurrency, logFrequency)
+	case len(pyramid) > 0:
+		score = model.OptimizePyramid(pyramid, concurrency, logFrequency)
+	default:
+		score = model.Optimize(iterations, concurrency, logFrequency)
+	}
 	elapsed := time.Since(start)
 
 	// logging info
@@ -119,6 +497,15 @@ func main() {
 	fmt.Printf("score: %v", score)
 
 	// saving output
+	width, height := model.OutputSize()
+	if outputSize != "" {
+		var err error
+		width, height, err = parseSize(outputSize, width, height)
+		if err != nil {
+			log.Printf("invalid output size: %v", err)
+			return
+		}
+	}
 	for _, output := range Outputs {
 		path := output
 		extension := strings.ToLower(filepath.Ext(output))
@@ -127,7 +514,7 @@ func main() {
 			log.Printf("unrecognized file extension: %s", extension)
 			return
 		case ".svg":
-			err := poly.SaveFile(path, model.SVG())
+			err := poly.SaveFile(path, model.SVGAt(width, height))
 			if err != nil {
 				log.Printf("unable to save SVG file: %v", err)
 				return
@@ -139,7 +526,7 @@ func main() {
 				return
 			}
 		case ".png":
-			err := model.PNG(output)
+			err := model.PNGAt(output, width, height)
 			if err != nil {
 				log.Printf("unable to save PNG file: %v", err)
 				return
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size          string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"300x200", 150, 100, 300, 200},
+		{"300x50", 150, 100, 300, 50},
+		{"300", 150, 100, 300, 200},
+		{"300", 100, 150, 200, 300},
+		{"100", 150, 103, 100, 69},
+	}
+	for _, test := range tests {
+		w, h, err := parseSize(test.size, test.width, test.height)
+		if err != nil {
+			t.Errorf("%q: %v", test.size, err)
+			continue
+		}
+		if w != test.wantW || h != test.wantH {
+			t.Errorf("%q for %vx%v: %vx%v, want %vx%v", test.size, test.width, test.height, w, h, test.wantW, test.wantH)
+		}
+	}
+	for _, size := range []string{"0x10", "10x", "x10", "-5", "big"} {
+		if _, _, err := parseSize(size, 150, 100); err == nil {
+			t.Errorf("%q accepted", size)
+		}
+	}
+}
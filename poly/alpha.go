@@ -0,0 +1,83 @@
+package poly
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// AlphaRange bounds the alpha of the polygon colors. Min equal to Max fixes
+// the alpha of every polygon.
+type AlphaRange struct {
+	Min, Max uint8
+}
+
+func NewAlphaRange(min, max int) (*AlphaRange, error) {
+	if min < 0 || max > 255 || min > max {
+		return nil, fmt.Errorf("invalid alpha range %v-%v", min, max)
+	}
+	return &AlphaRange{uint8(min), uint8(max)}, nil
+}
+
+// FixedAlpha gives the same alpha to every polygon.
+func FixedAlpha(alpha uint8) *AlphaRange {
+	return &AlphaRange{alpha, alpha}
+}
+
+// OpaqueAlpha makes every polygon opaque.
+func OpaqueAlpha() *AlphaRange {
+	return FixedAlpha(255)
+}
+
+// clamp returns the color with its alpha moved inside the range, a nil range
+// allows every alpha.
+func (r *AlphaRange) clamp(c Color) Color {
+	if r == nil {
+		return c
+	}
+	if c.A < r.Min {
+		c.A = r.Min
+	}
+	if c.A > r.Max {
+		c.A = r.Max
+	}
+	return c
+}
+
+// randomColor returns a random color with an alpha uniformly picked inside
+// the range, or any alpha for a nil range.
+func (r *AlphaRange) randomColor() Color {
+	c := NewRandomColor()
+	if r != nil {
+		c.A = r.random()
+	}
+	return c
+}
+
+// newColor returns the color of a new polygon. Its alpha is uniformly picked
+// inside the range, or the default one of new polygons for a nil range.
+func (r *AlphaRange) newColor() Color {
+	c := newRandomColor()
+	if r != nil {
+		c.A = r.random()
+	}
+	return c
+}
+
+func (r *AlphaRange) random() uint8 {
+	return r.Min + uint8(rand.Intn(int(r.Max)-int(r.Min)+1))
+}
+
+// SetAlpha bounds the alpha of the polygons, clamping the alpha of the
+// current ones, like the ones read from a GOB file, and rescores the model.
+// Polygons created afterwards get an alpha inside the range. A nil range
+// allows every alpha.
+func (m *Model) SetAlpha(r *AlphaRange) {
+	m.Alpha = r
+	polygons := make(Polygons, len(m.Polygons))
+	for i, polygon := range m.Polygons {
+		polygon.Color = r.clamp(polygon.Color)
+		polygons[i] = polygon
+	}
+	m.Polygons = polygons
+	m.prepare()
+}
@@ -0,0 +1,53 @@
+package poly
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAlphaRangeEnforced(t *testing.T) {
+	rand.Seed(13)
+	model := NewModel(decodeTestImage(t), 10, 1, Color{255, 255, 255, 255})
+	r, err := NewAlphaRange(100, 140)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetAlpha(r)
+	for _, kind := range []ColorMutationKind{RandomColorMutation, UniformColorMutation, GaussianColorMutation} {
+		mutations := DefaultMutations()
+		mutations.Vertex = 0
+		mutations.ColorMutation = kind
+		mutations.ColorSigma = 100
+		model.Mutations = mutations
+		model.Optimize(50, 1, 0)
+		for _, polygon := range model.Polygons {
+			if polygon.Color.A < 100 || polygon.Color.A > 140 {
+				t.Fatalf("%v: alpha %v outside [100, 140]", kind, polygon.Color.A)
+			}
+		}
+	}
+
+	model.SetAlpha(OpaqueAlpha())
+	for _, polygon := range model.Polygons {
+		if polygon.Color.A != 255 {
+			t.Fatalf("opaque model has alpha %v", polygon.Color.A)
+		}
+	}
+	model.Alpha = r
+	if err := model.Initialize(RandomInit); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[uint8]bool{}
+	for _, polygon := range model.Polygons {
+		if polygon.Color.A < 100 || polygon.Color.A > 140 {
+			t.Fatalf("new polygon with alpha %v outside [100, 140]", polygon.Color.A)
+		}
+		seen[polygon.Color.A] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("new polygons share the alpha %v", seen)
+	}
+	if _, err := NewAlphaRange(200, 100); err == nil {
+		t.Errorf("an empty alpha range should fail")
+	}
+}
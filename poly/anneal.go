@@ -0,0 +1,98 @@
+package poly
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+type ScheduleKind string
+
+const (
+	LinearSchedule      ScheduleKind = "linear"
+	ExponentialSchedule ScheduleKind = "exponential"
+	AdaptiveSchedule    ScheduleKind = "adaptive"
+)
+
+// Schedule is the temperature schedule of the simulated annealing optimizer.
+// Temperatures are relative to the current score, so a temperature of 0.01
+// accepts a candidate 1% worse than the current model with probability 1/e.
+type Schedule struct {
+	Kind ScheduleKind
+	// Initial and Final are the temperatures at the start and at the end of a run
+	Initial, Final float64
+	// Patience is the number of iterations without improvement after which
+	// the adaptive schedule reheats
+	Patience int
+	// Reheat multiplies the temperature of the adaptive schedule on every reheat
+	Reheat float64
+}
+
+func NewSchedule(kind ScheduleKind, initial, final float64) (*Schedule, error) {
+	switch kind {
+	case LinearSchedule, ExponentialSchedule, AdaptiveSchedule:
+	default:
+		return nil, fmt.Errorf("unknown schedule %q", kind)
+	}
+	if initial <= 0 || final <= 0 || final > initial {
+		return nil, fmt.Errorf("invalid temperatures %v -> %v", initial, final)
+	}
+	s := Schedule{
+		Kind:     kind,
+		Initial:  initial,
+		Final:    final,
+		Patience: 1000,
+		Reheat:   2,
+	}
+	return &s, nil
+}
+
+// annealer keeps the state of a schedule during a single Optimize run.
+type annealer struct {
+	schedule    *Schedule
+	temperature float64
+	stalled     int
+}
+
+func newAnnealer(s *Schedule) *annealer {
+	return &annealer{schedule: s, temperature: s.Initial}
+}
+
+// cool updates the temperature for iteration i of n. improved tells whether
+// the model score improved in the previous iteration.
+func (a *annealer) cool(i, n int, improved bool) {
+	s := a.schedule
+	progress := float64(i) / float64(n)
+	switch s.Kind {
+	case LinearSchedule:
+		a.temperature = s.Initial + (s.Final-s.Initial)*progress
+	case ExponentialSchedule:
+		a.temperature = s.Initial * math.Pow(s.Final/s.Initial, progress)
+	case AdaptiveSchedule:
+		// cools exponentially at the same rate as the exponential schedule
+		// and reheats every time the run stalls for too long
+		a.temperature *= math.Pow(s.Final/s.Initial, 1/float64(n))
+		if improved {
+			a.stalled = 0
+		} else {
+			a.stalled++
+		}
+		if s.Patience > 0 && a.stalled >= s.Patience {
+			a.temperature = math.Min(a.temperature*s.Reheat, s.Initial)
+			a.stalled = 0
+		}
+	}
+}
+
+// accept is the Metropolis criterion: better candidates are always accepted
+// and worse ones with a probability that decreases with the temperature.
+func (a *annealer) accept(current, next float64) bool {
+	if next < current {
+		return true
+	}
+	if a.temperature <= 0 || current == 0 {
+		return false
+	}
+	delta := (next - current) / math.Abs(current)
+	return rand.Float64() < math.Exp(-delta/a.temperature)
+}
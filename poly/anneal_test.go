@@ -0,0 +1,106 @@
+package poly
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCool(t *testing.T) {
+	for _, test := range []struct {
+		kind ScheduleKind
+		i    int
+		want float64
+	}{
+		{LinearSchedule, 0, 0.1},
+		{LinearSchedule, 50, 0.0505},
+		{LinearSchedule, 100, 0.001},
+		{ExponentialSchedule, 50, 0.01},
+		{ExponentialSchedule, 100, 0.001},
+	} {
+		s, err := NewSchedule(test.kind, 0.1, 0.001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := newAnnealer(s)
+		a.cool(test.i, 100, false)
+		if math.Abs(a.temperature-test.want) > 1e-12 {
+			t.Errorf("%v at %v: temperature %v, want %v", test.kind, test.i, a.temperature, test.want)
+		}
+	}
+}
+
+func TestCoolAdaptive(t *testing.T) {
+	s, err := NewSchedule(AdaptiveSchedule, 0.1, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Patience = 10
+	a := newAnnealer(s)
+	for i := 1; i <= 100; i++ {
+		a.cool(i, 100, true)
+	}
+	if math.Abs(a.temperature-0.001) > 1e-9 {
+		t.Errorf("improving run ended at %v, want 0.001", a.temperature)
+	}
+
+	// without improvements the temperature doubles every 10 iterations
+	for i := 1; i < 10; i++ {
+		a.cool(i, 100, false)
+	}
+	cooled := a.temperature
+	a.cool(10, 100, false)
+	if want := 2 * cooled * math.Pow(0.01, 0.01); math.Abs(a.temperature-want) > 1e-12 {
+		t.Errorf("reheated to %v, want %v", a.temperature, want)
+	}
+	for i := 0; i < 1000; i++ {
+		a.cool(i, 100, false)
+	}
+	if a.temperature > s.Initial {
+		t.Errorf("reheated above the initial temperature to %v", a.temperature)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	rand.Seed(1)
+	a := &annealer{}
+	if !a.accept(100, 99) {
+		t.Error("a better candidate was rejected")
+	}
+	if a.accept(100, 101) {
+		t.Error("a worse candidate was accepted at temperature 0")
+	}
+
+	// a candidate 1% worse at temperature 0.01 is accepted with
+	// probability 1/e
+	a.temperature = 0.01
+	accepted := 0
+	for i := 0; i < 10000; i++ {
+		if a.accept(100, 101) {
+			accepted++
+		}
+	}
+	if rate := float64(accepted) / 10000; math.Abs(rate-1/math.E) > 0.02 {
+		t.Errorf("acceptance rate %v, want %v", rate, 1/math.E)
+	}
+}
+
+func TestOptimizeAnnealingKeepsBest(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 20, 1, Color{255, 255, 255, 255})
+	initial := model.Score
+	// hot enough to accept most worse candidates
+	s, err := NewSchedule(LinearSchedule, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.Annealing = s
+	score := model.Optimize(300, 2, 0)
+
+	if score > initial {
+		t.Errorf("annealing ended at %v, worse than the initial %v", score, initial)
+	}
+	if score != model.Score {
+		t.Errorf("returned %v, model scored %v", score, model.Score)
+	}
+	checkIncrementalScore(t, model, score)
+}
@@ -0,0 +1,164 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// antialiasSamples is the number of samples per pixel side used to estimate
+// the coverage of a pixel
+const antialiasSamples = 4
+
+// fcrossing is the position where a sub-scanline crosses an edge.
+type fcrossing struct {
+	x   float64
+	dir int
+}
+
+// rasterizePolygonAA paints the polygon on the canvas blending every pixel by
+// the fraction of it covered by the polygon. The coverage is estimated with
+// a grid of antialiasSamples x antialiasSamples samples around the pixel
+// center, so a pixel fully inside the polygon is painted exactly like
+// rasterizePolygon does.
+func rasterizePolygonAA(polygon Polygon, rule FillRule, canvas *image.RGBA) {
+	if len(polygon.Vertices) < 3 {
+		return
+	}
+	edges := newEdgeTable(polygon.Vertices)
+	if len(edges) == 0 {
+		return
+	}
+	bounds := canvas.Rect.Intersect(polygon.bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	const samples = antialiasSamples
+	coverage := make([]int, bounds.Dx())
+	var crossings []fcrossing
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		for j := 0; j < samples; j++ {
+			sy := float64(y) - 0.5 + (float64(j)+0.5)/samples
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if e.y0 <= sy && sy < e.y1 {
+					x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+					crossings = append(crossings, fcrossing{x, e.dir})
+				}
+			}
+			sort.Slice(crossings, func(a, b int) bool {
+				return crossings[a].x < crossings[b].x
+			})
+			winding := 0
+			for k := 0; k < len(crossings)-1; k++ {
+				winding += crossings[k].dir
+				if !rule.inside(winding) {
+					continue
+				}
+				// samples of the row are at u/samples - 0.5 + 0.5/samples
+				// for every integer u, the ones inside [x0, x1) are covered
+				u0 := int(math.Ceil((crossings[k].x+0.5)*samples - 0.5))
+				u1 := int(math.Ceil((crossings[k+1].x+0.5)*samples - 0.5))
+				if lo := bounds.Min.X * samples; u0 < lo {
+					u0 = lo
+				}
+				if hi := bounds.Max.X * samples; u1 > hi {
+					u1 = hi
+				}
+				for u := u0; u < u1; u++ {
+					coverage[u/samples-bounds.Min.X]++
+				}
+			}
+		}
+		for i, c := range coverage {
+			if c == 0 {
+				continue
+			}
+			color := polygon.Color
+			color.A = uint8(int(color.A) * c / (samples * samples))
+			if c < samples*samples && color.A == 0 {
+				continue
+			}
+			drawPoint(bounds.Min.X+i, y, color, canvas)
+		}
+	}
+}
+
+// antialiasBand is the number of canvas rows renderAA supersamples at once.
+const antialiasBand = 32
+
+// renderAA paints the polygons on the canvas with anti-aliasing. The scene is
+// rendered antialiasSamples times larger on each axis, a band of rows at a
+// time, and every pixel gets the mean of its samples. Blending every polygon
+// by its own coverage instead would let the background show through the
+// edges two polygons share, where each one covers half of the pixel.
+func renderAA(polygons Polygons, rule FillRule, canvas *image.RGBA) {
+	const s = antialiasSamples
+	// the samples of pixel x are at x - 0.5 + (u+0.5)/s, which is the center
+	// of the pixel x*s+u of the larger scene
+	scaled := make(Polygons, len(polygons))
+	for i, polygon := range polygons {
+		scaled[i] = Polygon{Color: polygon.Color, Vertices: make([]Point, len(polygon.Vertices))}
+		for j, v := range polygon.Vertices {
+			scaled[i].Vertices[j] = Point{(v.X+0.5)*s - 0.5, (v.Y+0.5)*s - 0.5}
+		}
+	}
+	r := canvas.Rect
+	for y0 := r.Min.Y; y0 < r.Max.Y; y0 += antialiasBand {
+		y1 := y0 + antialiasBand
+		if y1 > r.Max.Y {
+			y1 = r.Max.Y
+		}
+		band := image.NewRGBA(image.Rect(r.Min.X*s, y0*s, r.Max.X*s, y1*s))
+		for y := band.Rect.Min.Y; y < band.Rect.Max.Y; y++ {
+			for x := band.Rect.Min.X; x < band.Rect.Max.X; x++ {
+				c := canvas.PixOffset(floorDiv(x, s), floorDiv(y, s))
+				copy(band.Pix[band.PixOffset(x, y):], canvas.Pix[c:c+4])
+			}
+		}
+		for _, polygon := range scaled {
+			if polygon.bounds().Overlaps(band.Rect) {
+				rasterizePolygon(polygon, rule, band)
+			}
+		}
+		for y := y0; y < y1; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				var sum [4]int
+				for v := 0; v < s; v++ {
+					b := band.PixOffset(x*s, y*s+v)
+					for u := 0; u < s; u++ {
+						for c := 0; c < 4; c++ {
+							sum[c] += int(band.Pix[b+4*u+c])
+						}
+					}
+				}
+				p := canvas.PixOffset(x, y)
+				for c := 0; c < 4; c++ {
+					canvas.Pix[p+c] = uint8((sum[c] + s*s/2) / (s * s))
+				}
+			}
+		}
+	}
+}
+
+// floorDiv returns a / b rounded down, also for negative a.
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((b - 1 - a) / b)
+	}
+	return a / b
+}
+
+// rasterize paints the polygon on the canvas, anti-aliased when antialias is
+// set.
+func rasterize(polygon Polygon, rule FillRule, antialias bool, canvas *image.RGBA) {
+	if antialias {
+		rasterizePolygonAA(polygon, rule, canvas)
+	} else {
+		rasterizePolygon(polygon, rule, canvas)
+	}
+}
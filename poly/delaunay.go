@@ -0,0 +1,108 @@
+package poly
+
+// delaunay returns the Delaunay triangulation of the points as triples of
+// indices, using the Bowyer-Watson algorithm: every point is inserted by
+// removing the triangles whose circumcircle contains it and connecting it to
+// the boundary of the hole they leave.
+func delaunay(points []Point) [][3]int {
+	if len(points) < 3 {
+		return nil
+	}
+	minX, maxX, minY, maxY := minMaxPoints(points)
+	size := maxX - minX
+	if maxY-minY > size {
+		size = maxY - minY
+	}
+	if size == 0 {
+		return nil
+	}
+	// a super triangle containing every point, its vertices go after the
+	// points and are removed at the end. It has to be far bigger than the
+	// points, otherwise its vertices fall inside the circumcircles of the hull
+	// edges and the triangles along the hull are lost with them.
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	vertices := make([]Point, len(points), len(points)+3)
+	copy(vertices, points)
+	n := len(points)
+	vertices = append(vertices,
+		Point{cx - 1e5*size, cy - size},
+		Point{cx, cy + 1e5*size},
+		Point{cx + 1e5*size, cy - size},
+	)
+
+	triangles := []circumTriangle{newCircumTriangle(vertices, n, n+1, n+2)}
+	for i := 0; i < n; i++ {
+		p := vertices[i]
+		// edges of the removed triangles, the ones seen twice are inside the
+		// hole and the rest are its boundary
+		var edges [][2]int
+		kept := triangles[:0]
+		for _, t := range triangles {
+			if t.contains(p) {
+				edges = append(edges, [2]int{t.v[0], t.v[1]}, [2]int{t.v[1], t.v[2]}, [2]int{t.v[2], t.v[0]})
+			} else {
+				kept = append(kept, t)
+			}
+		}
+		triangles = kept
+		for j, e := range edges {
+			shared := false
+			for k, f := range edges {
+				if j != k && (e == f || e[0] == f[1] && e[1] == f[0]) {
+					shared = true
+					break
+				}
+			}
+			if !shared {
+				triangles = append(triangles, newCircumTriangle(vertices, e[0], e[1], i))
+			}
+		}
+	}
+
+	var result [][3]int
+	for _, t := range triangles {
+		if t.v[0] < n && t.v[1] < n && t.v[2] < n {
+			result = append(result, t.v)
+		}
+	}
+	return result
+}
+
+// circumTriangle is a triangle of the triangulation with its circumcircle.
+type circumTriangle struct {
+	v         [3]int
+	center    Point
+	radius2   float64
+	collinear bool
+}
+
+func newCircumTriangle(vertices []Point, a, b, c int) circumTriangle {
+	t := circumTriangle{v: [3]int{a, b, c}}
+	pa, pb, pc := vertices[a], vertices[b], vertices[c]
+	d := 2 * (pa.X*(pb.Y-pc.Y) + pb.X*(pc.Y-pa.Y) + pc.X*(pa.Y-pb.Y))
+	if d == 0 {
+		t.collinear = true
+		return t
+	}
+	a2 := pa.X*pa.X + pa.Y*pa.Y
+	b2 := pb.X*pb.X + pb.Y*pb.Y
+	c2 := pc.X*pc.X + pc.Y*pc.Y
+	t.center = Point{
+		(a2*(pb.Y-pc.Y) + b2*(pc.Y-pa.Y) + c2*(pa.Y-pb.Y)) / d,
+		(a2*(pc.X-pb.X) + b2*(pa.X-pc.X) + c2*(pb.X-pa.X)) / d,
+	}
+	dx, dy := pa.X-t.center.X, pa.Y-t.center.Y
+	t.radius2 = dx*dx + dy*dy
+	return t
+}
+
+// contains tells whether p is inside the circumcircle. The circumcircle of a
+// flat triangle is a half plane, it is treated as containing every point so
+// the triangle is replaced as soon as possible.
+func (t circumTriangle) contains(p Point) bool {
+	if t.collinear {
+		return true
+	}
+	dx, dy := p.X-t.center.X, p.Y-t.center.Y
+	return dx*dx+dy*dy < t.radius2
+}
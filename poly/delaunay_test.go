@@ -0,0 +1,92 @@
+package poly
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkTriangulation checks that no point is inside the circumcircle of a
+// triangle and that the triangles cover the width x height rectangle that
+// is the convex hull of the points.
+func checkTriangulation(t *testing.T, points []Point, triangles [][3]int, width, height float64) {
+	t.Helper()
+	var area float64
+	for _, tr := range triangles {
+		c := newCircumTriangle(points, tr[0], tr[1], tr[2])
+		for i, p := range points {
+			if i != tr[0] && i != tr[1] && i != tr[2] && c.contains(p) {
+				t.Fatalf("point %v inside the circumcircle of %v", p, tr)
+			}
+		}
+		a, b, d := points[tr[0]], points[tr[1]], points[tr[2]]
+		cross := (b.X-a.X)*(d.Y-a.Y) - (d.X-a.X)*(b.Y-a.Y)
+		if cross < 0 {
+			cross = -cross
+		}
+		area += cross / 2
+	}
+	if want := width * height; area < want-1e-6 || area > want+1e-6 {
+		t.Errorf("triangles cover an area of %v, want %v", area, want)
+	}
+}
+
+func TestDelaunay(t *testing.T) {
+	rand.Seed(17)
+	points := []Point{{0, 0}, {99, 0}, {0, 79}, {99, 79}}
+	for i := 0; i < 60; i++ {
+		points = append(points, Point{1 + rand.Float64()*97, 1 + rand.Float64()*77})
+	}
+	triangles := delaunay(points)
+	if want := 2*len(points) - 6; len(triangles) != want {
+		t.Fatalf("%v triangles, want %v", len(triangles), want)
+	}
+	checkTriangulation(t, points, triangles, 99, 79)
+}
+
+func TestDelaunayPointsNearTheHull(t *testing.T) {
+	// like the delaunay initialization, the corners are half a pixel outside
+	// the canvas and the sampled points are clamped on the first and last
+	// rows and columns, just inside the hull
+	for _, n := range []int{200, 1000} {
+		rand.Seed(19)
+		points := []Point{{-0.5, -0.5}, {99.5, -0.5}, {-0.5, 79.5}, {99.5, 79.5}}
+		for len(points) < n {
+			points = append(points, pixelPoint(rand.Intn(100), rand.Intn(80), 100, 80))
+		}
+		for i := 0; i < 40; i++ {
+			points = append(points, Point{rand.Float64() * 99, 79}, Point{99, rand.Float64() * 79})
+		}
+		checkTriangulation(t, points, delaunay(points), 100, 80)
+	}
+}
+func TestDelaunayInit(t *testing.T) {
+	img := decodeTestImage(t)
+	for _, budget := range []int{60, 400, 2000} {
+		random, err := NewModelWithInit(img, budget, 1, Color{255, 255, 255, 255}, RandomInit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := NewModelWithInit(img, budget, 1, Color{255, 255, 255, 255}, DelaunayInit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(model.Polygons); n < budget*5/6 || n > model.NumPolygons {
+			t.Fatalf("delaunay initialization created %v triangles for a budget of %v", n, budget)
+		}
+		for _, polygon := range model.Polygons {
+			if len(polygon.Vertices) != 3 || polygon.Color.A != 255 {
+				t.Fatalf("polygon %v is not an opaque triangle", polygon)
+			}
+		}
+		magenta := Color{255, 0, 255, 255}
+		canvas := polygonsToRGBA(model.Polygons, magenta, model.FillRule, model.AntiAlias, model.Width, model.Height)
+		for i := 0; i < len(canvas.Pix); i += 4 {
+			if canvas.Pix[i] == 255 && canvas.Pix[i+1] == 0 && canvas.Pix[i+2] == 255 {
+				t.Fatalf("pixel %v is not covered by any triangle with a budget of %v", i/4, budget)
+			}
+		}
+		if model.Score >= random.Score/2 {
+			t.Errorf("delaunay initialization score %v, random initialization %v", model.Score, random.Score)
+		}
+	}
+}
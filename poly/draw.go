@@ -56,3 +56,14 @@ func clamp(a, b, min, max int) uint8 {
 	}
 	return uint8(a + b)
 }
+
+// clampFloat returns v limited to the interval [min, max].
+func clampFloat(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+package poly
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// OptimizeGreedy adds polygons one at a time on top of the current ones
+// until the model has NumPolygons of them. Every new polygon is the best of
+// restarts random ones, improved by hill climbing for the given number of
+// iterations and then frozen, so the polygons end up ordered by how much
+// they reduce the error and the model can be truncated to any size. It stops
+// early when a new polygon cannot improve the score. Starting from a model
+// without polygons builds it from the background.
+func (m *Model) OptimizeGreedy(restarts, iterations, concurrency, logFrequency int) float64 {
+	if restarts < 1 {
+		restarts = 1
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if m.canvas == nil {
+		m.prepare()
+	}
+	var i, successful int
+	step := func(improved bool) {
+		i++
+		if improved {
+			successful++
+		}
+		if logFrequency > 0 && i%logFrequency == 0 {
+			m.logProgress(successful, nil)
+		}
+	}
+	for len(m.Polygons) < m.NumPolygons {
+		if !m.addPolygon(restarts, iterations, concurrency, step) {
+			fmt.Printf("no new polygon improves the score, stopping at %v polygons\n", len(m.Polygons))
+			break
+		}
+	}
+
+	fmt.Printf("successful iterations: %v\n", successful)
+
+	return m.Score
+}
+
+// addPolygon picks the best of restarts random polygons on top of the
+// current ones and improves it by hill climbing, calling step after every
+// iteration. The polygon is only added if it improves the score, it returns
+// whether it was.
+func (m *Model) addPolygon(restarts, iterations, concurrency int, step func(improved bool)) bool {
+	mt := m.mutator()
+	i := len(m.Polygons)
+	starts := make([]candidate, restarts)
+	parallel(restarts, concurrency, func(j int) {
+		polygons := make(Polygons, i+1)
+		copy(polygons, m.Polygons)
+		polygon := mt.newRandomPolygon()
+		polygons[i] = polygon
+		if mt.fitColor != nil {
+			polygons[i].Color = mt.fitColor(polygons, i)
+		}
+		starts[j] = m.scoreRegion(polygons, polygon.bounds())
+	})
+	current := starts[0]
+	for _, c := range starts[1:] {
+		if c.score < current.score {
+			current = c
+		}
+	}
+
+	// the new polygon is only committed at the end, the candidates only
+	// differ from the canvas inside its bounds
+	candidates := make([]candidate, concurrency)
+	for k := 0; k < iterations; k++ {
+		parallel(concurrency, concurrency, func(j int) {
+			polygons := make(Polygons, i+1)
+			copy(polygons, current.polygons)
+			kind := mt.pick(&polygons[i], 1)
+			dirty := mt.mutatePolygon(polygons, i, kind, rand.Float64())
+			candidates[j] = m.scoreRegion(polygons, dirty)
+		})
+		best := candidates[0]
+		for _, c := range candidates[1:] {
+			if c.score < best.score {
+				best = c
+			}
+		}
+		improved := best.score < current.score
+		if improved {
+			current = best
+		}
+		m.Iteration++
+		step(improved)
+	}
+	if current.score >= m.Score {
+		return false
+	}
+	m.commit(current)
+	return true
+}
+
+// Truncate keeps only the first n polygons of the model, the ones drawn
+// first, and rescores it.
+func (m *Model) Truncate(n int) {
+	if n < len(m.Polygons) {
+		m.Polygons = m.Polygons[:n:n]
+	}
+	m.NumPolygons = len(m.Polygons)
+	m.prepare()
+}
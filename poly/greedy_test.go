@@ -0,0 +1,42 @@
+package poly
+
+import (
+	"testing"
+)
+
+func TestOptimizeGreedy(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 10, 1, Color{255, 255, 255, 255})
+	model.Polygons = nil
+	model.Rescore()
+	background := model.Score
+
+	score := model.OptimizeGreedy(5, 20, 2, 0)
+	if len(model.Polygons) != 10 {
+		t.Fatalf("greedy model has %v polygons, want 10", len(model.Polygons))
+	}
+	if score >= background {
+		t.Errorf("greedy score %v did not improve the background %v", score, background)
+	}
+	checkIncrementalScore(t, model, score)
+
+	model.Truncate(4)
+	if len(model.Polygons) != 4 || model.NumPolygons != 4 {
+		t.Fatalf("truncated model has %v polygons", len(model.Polygons))
+	}
+	if model.Score < score || model.Score >= background {
+		t.Errorf("truncated score %v should be between %v and %v", model.Score, score, background)
+	}
+}
+
+func TestOptimizeGreedyKeepsOnlyImprovements(t *testing.T) {
+	white := Color{255, 255, 255, 255}
+	model := NewModel(uniformImage(20, 10, white), 3, 1, white)
+	model.Polygons = nil
+	model.Rescore()
+
+	// the background already matches the target, so every polygon would
+	// make the score worse
+	if score := model.OptimizeGreedy(3, 10, 1, 0); len(model.Polygons) != 0 || score != 0 {
+		t.Errorf("greedy added %v polygons with score %v to a perfect model", len(model.Polygons), score)
+	}
+}
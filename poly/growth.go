@@ -0,0 +1,171 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// Growth adds polygons during Optimize, one at a time, until the model has
+// NumPolygons of them. New polygons are placed where the current error is
+// highest.
+type Growth struct {
+	// Every adds a polygon every that many iterations, 0 disables it
+	Every int
+	// Patience adds a polygon after that many iterations without
+	// improvement, 0 disables it
+	Patience int
+}
+
+// grower keeps the state of the growth during a single Optimize run.
+type grower struct {
+	growth  *Growth
+	stalled int
+}
+
+// due tells whether a polygon should be added at iteration i given whether
+// the previous iteration improved the model.
+func (g *grower) due(i int, improved bool) bool {
+	if improved {
+		g.stalled = 0
+	} else {
+		g.stalled++
+	}
+	if g.growth.Every > 0 && i%g.growth.Every == 0 {
+		return true
+	}
+	if g.growth.Patience > 0 && g.stalled >= g.growth.Patience {
+		g.stalled = 0
+		return true
+	}
+	return false
+}
+
+// grow adds a polygon on top of the others around a pixel picked with a
+// probability proportional to its current error, with the color that best
+// fits the target there. The polygon is always kept, even if it makes the
+// score worse.
+func (m *Model) grow() {
+	polygon := m.newResidualPolygon(m.newResidualMap(), m.side()/8)
+	polygons := make(Polygons, len(m.Polygons), len(m.Polygons)+1)
+	copy(polygons, m.Polygons)
+	polygons = append(polygons, polygon)
+	i := len(polygons) - 1
+	polygons[i].Color = m.optimalColor(polygons, i)
+	m.commit(m.scoreRegion(polygons, polygons[i].bounds()))
+}
+
+// side is the largest side of the model.
+func (m *Model) side() float64 {
+	return math.Max(float64(m.Width), float64(m.Height))
+}
+
+// newResidualPolygon returns a random polygon around a pixel picked from the
+// residual map.
+func (m *Model) newResidualPolygon(r *residualMap, radius float64) Polygon {
+	mt := m.mutator()
+	center := r.point()
+	polygon := newPolygonAround(center, math.Max(2, radius), mt.order(), m.Width, m.Height)
+	polygon.Color = mt.alpha.newColor()
+	return polygon
+}
+
+// residualMap picks pixels with a probability proportional to their squared
+// error, weighted by the mask. The errors are kept in a Fenwick tree, so
+// after adding a polygon only the pixels under it have to be updated.
+type residualMap struct {
+	m        *Model
+	residual []float64
+	// tree holds the partial sums of the residuals, from index 1
+	tree []float64
+}
+
+func (m *Model) newResidualMap() *residualMap {
+	if m.canvas == nil {
+		m.prepare()
+	}
+	rect := m.TargetImage.Rect
+	n := rect.Dx() * rect.Dy()
+	r := residualMap{m: m, residual: make([]float64, n), tree: make([]float64, n+1)}
+	for i := range r.residual {
+		r.residual[i] = r.at(rect.Min.X+i%rect.Dx(), rect.Min.Y+i/rect.Dx())
+		r.tree[i+1] += r.residual[i]
+		if j := i + 1 + (i+1)&-(i+1); j <= n {
+			r.tree[j] += r.tree[i+1]
+		}
+	}
+	return &r
+}
+
+// at returns the weighted squared error of a pixel of the canvas.
+func (r *residualMap) at(x, y int) float64 {
+	m := r.m
+	t := m.TargetImage.PixOffset(x, y)
+	c := m.canvas.PixOffset(x, y)
+	var e float64
+	for i := 0; i < 3; i++ {
+		d := float64(m.TargetImage.Pix[t+i]) - float64(m.canvas.Pix[c+i])
+		e += d * d
+	}
+	return e * float64(weights{m.Mask}.at(x, y))
+}
+
+// update recomputes the residuals inside rect after the canvas changed.
+func (r *residualMap) update(rect image.Rectangle) {
+	bounds := r.m.TargetImage.Rect
+	rect = rect.Intersect(bounds)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+			e := r.at(x, y)
+			for j := i + 1; j < len(r.tree); j += j & -j {
+				r.tree[j] += e - r.residual[i]
+			}
+			r.residual[i] = e
+		}
+	}
+}
+
+// point returns a random position inside a pixel picked with a probability
+// proportional to its residual, or uniformly when there is no error left.
+func (r *residualMap) point() Point {
+	m := r.m
+	var total float64
+	for j := len(r.residual); j > 0; j -= j & -j {
+		total += r.tree[j]
+	}
+	if total <= 0 {
+		return pixelPoint(rand.Intn(m.Width), rand.Intn(m.Height), m.Width, m.Height)
+	}
+	// descend the tree to the first pixel whose running sum exceeds u
+	u := rand.Float64() * total
+	i := 0
+	step := 1
+	for step*2 < len(r.tree) {
+		step *= 2
+	}
+	for ; step > 0; step /= 2 {
+		if j := i + step; j < len(r.tree) && r.tree[j] <= u {
+			i = j
+			u -= r.tree[j]
+		}
+	}
+	if i >= len(r.residual) {
+		i = len(r.residual) - 1
+	}
+	bounds := m.TargetImage.Rect
+	return pixelPoint(bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx(), m.Width, m.Height)
+}
+
+// newPolygonAround returns a polygon with random vertices at most radius
+// away from center on each axis, kept inside the canvas.
+func newPolygonAround(center Point, radius float64, order, maxX, maxY int) Polygon {
+	polygon := Polygon{Vertices: make([]Point, order)}
+	for i := range polygon.Vertices {
+		polygon.Vertices[i] = Point{
+			clampFloat(center.X+(2*rand.Float64()-1)*radius, 0, float64(maxX-1)),
+			clampFloat(center.Y+(2*rand.Float64()-1)*radius, 0, float64(maxY-1)),
+		}
+	}
+	return polygon
+}
@@ -0,0 +1,56 @@
+package poly
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOptimizeGrowth(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 12, 1, Color{255, 255, 255, 255})
+	model.Polygons = model.Polygons[:2]
+	model.Growth = &Growth{Every: 20}
+	model.Rescore()
+
+	score := model.Optimize(300, 2, 0)
+	if len(model.Polygons) != 12 {
+		t.Fatalf("model grew to %v polygons, want 12", len(model.Polygons))
+	}
+	checkIncrementalScore(t, model, score)
+}
+
+func TestOptimizeGrowthFromBackground(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 3, 1, Color{255, 255, 255, 255})
+	model.Polygons = nil
+	model.Growth = &Growth{Patience: 10}
+	model.Rescore()
+	background := model.Score
+
+	if score := model.Optimize(100, 1, 0); len(model.Polygons) == 0 || score >= background {
+		t.Errorf("model grew to %v polygons with score %v from %v", len(model.Polygons), score, background)
+	}
+}
+
+func TestResidualMapUpdate(t *testing.T) {
+	white := Color{255, 255, 255, 255}
+	model := NewModel(uniformImage(16, 12, white), 1, 1, white)
+	model.Polygons = nil
+	model.Rescore()
+	residual := model.newResidualMap()
+
+	// a black square leaves error only under it
+	square := Polygon{Color: Color{A: 255}, Vertices: []Point{{3, 2}, {6, 2}, {6, 5}, {3, 5}}}
+	model.commit(model.scoreRegion(Polygons{square}, square.bounds()))
+	residual.update(square.bounds())
+	for i := 0; i < 200; i++ {
+		p := residual.point()
+		if p.X < 2.5 || p.X > 6.5 || p.Y < 1.5 || p.Y > 5.5 {
+			t.Fatalf("sampled %v outside the square", p)
+		}
+	}
+	rebuilt := model.newResidualMap()
+	for i, e := range rebuilt.tree {
+		if math.Abs(e-residual.tree[i]) > 1e-6 {
+			t.Fatalf("updated tree differs from a rebuilt one at %v: %v, want %v", i, residual.tree[i], e)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"sort"
+)
+
+type ImportanceKind string
+
+const (
+	// SobelImportance is the magnitude of the Sobel gradient
+	SobelImportance ImportanceKind = "sobel"
+	// VarianceImportance is the variance of the luminance around every pixel
+	VarianceImportance ImportanceKind = "variance"
+	// SaliencyImportance is the spectral residual saliency of Hou and Zhang
+	SaliencyImportance ImportanceKind = "saliency"
+)
+
+const (
+	// importanceFloor is the weight of the least important pixels relative
+	// to the most important ones, so no region is completely ignored
+	importanceFloor = 0.1
+	// saliencySize is the side of the thumbnail the saliency is computed on
+	saliencySize = 64
+)
+
+// ImportanceMap estimates how much detail every pixel of the image needs and
+// returns it as a grayscale mask that can be passed to SetMask.
+func ImportanceMap(kind ImportanceKind, img *image.RGBA) (*image.Gray, error) {
+	lum := luminance(img)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	var values []float64
+	switch kind {
+	case SobelImportance:
+		values = boxBlur(sobel(lum, w, h), w, h, 2)
+	case VarianceImportance:
+		values = localVariance(lum, w, h, 3)
+	case SaliencyImportance:
+		values = spectralResidual(lum, w, h)
+	default:
+		return nil, fmt.Errorf("unknown importance map %q", kind)
+	}
+
+	var max float64
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	mask := image.NewGray(img.Rect)
+	for i, v := range values {
+		weight := importanceFloor
+		if max > 0 {
+			weight += (1 - importanceFloor) * v / max
+		}
+		mask.Pix[mask.PixOffset(img.Rect.Min.X+i%w, img.Rect.Min.Y+i/w)] = uint8(math.Round(255 * weight))
+	}
+	return mask, nil
+}
+
+// SetImportance computes the importance map of the target image and uses it
+// as the mask of the model. New vertices are also placed with a probability
+// proportional to its brightness.
+func (m *Model) SetImportance(kind ImportanceKind) error {
+	mask, err := ImportanceMap(kind, m.TargetImage)
+	if err != nil {
+		return err
+	}
+	err = m.SetMask(mask)
+	if err != nil {
+		return err
+	}
+	m.Importance = kind
+	m.sampler = newSampler(m.Mask)
+	return nil
+}
+
+// luminance returns the Rec. 601 luma of every pixel, row by row.
+func luminance(img *image.RGBA) []float64 {
+	bounds := img.Rect
+	lum := make([]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		p := img.PixOffset(bounds.Min.X, y)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			lum = append(lum, 0.299*float64(img.Pix[p])+0.587*float64(img.Pix[p+1])+0.114*float64(img.Pix[p+2]))
+			p += 4
+		}
+	}
+	return lum
+}
+
+// at returns the value at (x, y) clamping the coordinates to the edges.
+func at(values []float64, w, h, x, y int) float64 {
+	if x < 0 {
+		x = 0
+	} else if x >= w {
+		x = w - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= h {
+		y = h - 1
+	}
+	return values[y*w+x]
+}
+
+func sobel(lum []float64, w, h int) []float64 {
+	magnitude := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(lum, w, h, x+1, y-1) + 2*at(lum, w, h, x+1, y) + at(lum, w, h, x+1, y+1) -
+				at(lum, w, h, x-1, y-1) - 2*at(lum, w, h, x-1, y) - at(lum, w, h, x-1, y+1)
+			gy := at(lum, w, h, x-1, y+1) + 2*at(lum, w, h, x, y+1) + at(lum, w, h, x+1, y+1) -
+				at(lum, w, h, x-1, y-1) - 2*at(lum, w, h, x, y-1) - at(lum, w, h, x+1, y-1)
+			magnitude[y*w+x] = math.Hypot(gx, gy)
+		}
+	}
+	return magnitude
+}
+
+// boxBlur averages every value with its neighbours up to radius away.
+func boxBlur(values []float64, w, h, radius int) []float64 {
+	blurred := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					sum += at(values, w, h, x+dx, y+dy)
+				}
+			}
+			blurred[y*w+x] = sum / float64((2*radius+1)*(2*radius+1))
+		}
+	}
+	return blurred
+}
+
+func localVariance(lum []float64, w, h, radius int) []float64 {
+	squares := make([]float64, len(lum))
+	for i, v := range lum {
+		squares[i] = v * v
+	}
+	mean := boxBlur(lum, w, h, radius)
+	meanSquares := boxBlur(squares, w, h, radius)
+	variance := make([]float64, len(lum))
+	for i := range variance {
+		variance[i] = math.Max(0, meanSquares[i]-mean[i]*mean[i])
+	}
+	return variance
+}
+
+// spectralResidual computes the saliency on a small thumbnail, as the
+// inverse Fourier transform of the log amplitude spectrum minus its local
+// average, keeping the original phase. The result is scaled back to the
+// image size.
+func spectralResidual(lum []float64, w, h int) []float64 {
+	n := saliencySize
+	spectrum := make([]complex128, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			spectrum[y*n+x] = complex(at(lum, w, h, x*w/n, y*h/n), 0)
+		}
+	}
+	fft2(spectrum, n, false)
+
+	logAmplitude := make([]float64, n*n)
+	for i, c := range spectrum {
+		logAmplitude[i] = math.Log(cmplx.Abs(c) + 1)
+	}
+	average := boxBlur(logAmplitude, n, n, 1)
+	for i, c := range spectrum {
+		spectrum[i] = cmplx.Rect(math.Exp(logAmplitude[i]-average[i]), cmplx.Phase(c))
+	}
+	fft2(spectrum, n, true)
+
+	saliency := make([]float64, n*n)
+	for i, c := range spectrum {
+		a := cmplx.Abs(c)
+		saliency[i] = a * a
+	}
+	saliency = boxBlur(boxBlur(saliency, n, n, 2), n, n, 2)
+
+	values := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			values[y*w+x] = saliency[(y*n/h)*n+x*n/w]
+		}
+	}
+	return values
+}
+
+// fft2 computes in place the 2D discrete Fourier transform of a n x n matrix,
+// n must be a power of two.
+func fft2(values []complex128, n int, inverse bool) {
+	column := make([]complex128, n)
+	for y := 0; y < n; y++ {
+		fft(values[y*n:(y+1)*n], inverse)
+	}
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			column[y] = values[y*n+x]
+		}
+		fft(column, inverse)
+		for y := 0; y < n; y++ {
+			values[y*n+x] = column[y]
+		}
+	}
+}
+
+// fft is the iterative radix-2 Cooley-Tukey transform. The inverse transform
+// is scaled by 1/n.
+func fft(values []complex128, inverse bool) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(length))
+		for i := 0; i < n; i += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				u, v := values[i+k], values[i+k+length/2]*w
+				values[i+k] = u + v
+				values[i+k+length/2] = u - v
+				w *= step
+			}
+		}
+	}
+	if inverse {
+		for i := range values {
+			values[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// sampler picks random pixels with a probability proportional to the
+// weights of a mask. A nil sampler picks pixels uniformly.
+type sampler struct {
+	// cumulative is the running sum of the weights, row by row
+	cumulative []int
+	bounds     image.Rectangle
+}
+
+func newSampler(mask *image.Gray) *sampler {
+	if mask == nil {
+		return nil
+	}
+	s := sampler{bounds: mask.Rect}
+	sum := 0
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			sum += int(mask.Pix[mask.PixOffset(x, y)])
+			s.cumulative = append(s.cumulative, sum)
+		}
+	}
+	return &s
+}
+
+// point returns a random position inside a random pixel with x in
+// [0, maxX) and y in [0, maxY), kept inside the canvas.
+func (s *sampler) point(maxX, maxY int) Point {
+	x, y := s.pixel(maxX, maxY)
+	return pixelPoint(x, y, maxX, maxY)
+}
+
+// pixelPoint returns a random position inside the pixel (x, y), kept inside
+// a maxX x maxY canvas.
+func pixelPoint(x, y, maxX, maxY int) Point {
+	return Point{
+		X: clampFloat(float64(x)+rand.Float64()-0.5, 0, float64(maxX-1)),
+		Y: clampFloat(float64(y)+rand.Float64()-0.5, 0, float64(maxY-1)),
+	}
+}
+
+// pixel returns a random pixel with x in [0, maxX) and y in [0, maxY).
+func (s *sampler) pixel(maxX, maxY int) (int, int) {
+	if s == nil || len(s.cumulative) == 0 || s.cumulative[len(s.cumulative)-1] == 0 {
+		return rand.Intn(maxX), rand.Intn(maxY)
+	}
+	r := rand.Intn(s.cumulative[len(s.cumulative)-1])
+	i := sort.SearchInts(s.cumulative, r+1)
+	w := s.bounds.Dx()
+	return s.bounds.Min.X + i%w, s.bounds.Min.Y + i/w
+}
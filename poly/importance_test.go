@@ -0,0 +1,92 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestImportanceMapFindsEdges(t *testing.T) {
+	// a flat image with a square in the middle
+	img := uniformImage(64, 64, Color{20, 20, 20, 255})
+	for y := 24; y < 40; y++ {
+		for x := 24; x < 40; x++ {
+			p := img.PixOffset(x, y)
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2] = 230, 230, 230
+		}
+	}
+	for _, kind := range []ImportanceKind{SobelImportance, VarianceImportance, SaliencyImportance} {
+		mask, err := ImportanceMap(kind, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edge, flat := mask.GrayAt(24, 32).Y, mask.GrayAt(4, 4).Y
+		if edge <= flat {
+			t.Errorf("%v: edge weight %v should be above flat weight %v", kind, edge, flat)
+		}
+		if flat == 0 {
+			t.Errorf("%v: flat regions should keep a minimum weight", kind)
+		}
+	}
+}
+
+func TestFFTRoundTrip(t *testing.T) {
+	values := make([]complex128, 16)
+	for i := range values {
+		values[i] = complex(float64(i*i%7), 0)
+	}
+	transformed := append([]complex128(nil), values...)
+	fft2(transformed, 4, false)
+	if cmplx.Abs(transformed[0]-complex(float64(sumOf(values)), 0)) > 1e-9 {
+		t.Errorf("DC component %v, want %v", transformed[0], sumOf(values))
+	}
+	fft2(transformed, 4, true)
+	for i := range values {
+		if cmplx.Abs(transformed[i]-values[i]) > 1e-9 {
+			t.Fatalf("value %d is %v after a round trip, want %v", i, transformed[i], values[i])
+		}
+	}
+}
+
+func sumOf(values []complex128) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += real(v)
+	}
+	return sum
+}
+
+func TestSamplerSkipsBlackPixels(t *testing.T) {
+	mask := image.NewGray(image.Rect(0, 0, 10, 10))
+	mask.Pix[mask.PixOffset(3, 7)] = 10
+	mask.Pix[mask.PixOffset(8, 1)] = 30
+	s := newSampler(mask)
+	counts := map[image.Point]int{}
+	for i := 0; i < 4000; i++ {
+		p := s.point(10, 10)
+		counts[image.Pt(int(math.Round(p.X)), int(math.Round(p.Y)))]++
+	}
+	if len(counts) != 2 {
+		t.Fatalf("sampled %v, want only the two bright pixels", counts)
+	}
+	if ratio := float64(counts[image.Pt(8, 1)]) / float64(counts[image.Pt(3, 7)]); math.Abs(ratio-3) > 0.5 {
+		t.Errorf("pixels sampled with ratio %v, want 3", ratio)
+	}
+}
+
+func TestOnlyImportanceBiasesVertices(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 5, 1, Color{255, 255, 255, 255})
+	if err := model.SetMask(model.TargetImage); err != nil {
+		t.Fatal(err)
+	}
+	if model.sampler != nil {
+		t.Error("a user mask biases the vertices")
+	}
+	if err := model.SetImportance(SobelImportance); err != nil {
+		t.Fatal(err)
+	}
+	if model.sampler == nil {
+		t.Error("an importance map does not bias the vertices")
+	}
+}
@@ -0,0 +1,109 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+)
+
+type InitKind string
+
+const (
+	// RandomInit places vertices uniformly at random with random colors
+	RandomInit InitKind = "random"
+	// ErrorInit adds polygons one at a time, from large to small, where the
+	// error of the previous ones is the highest, colored with the average
+	// target color under them
+	ErrorInit InitKind = "error"
+	// DelaunayInit covers the image with the opaque triangles of a Delaunay
+	// triangulation of points sampled along its edges, colored with the
+	// average target color under them
+	DelaunayInit InitKind = "delaunay"
+)
+
+// NewModelWithInit creates a model like NewModel, with its polygons created
+// by the given initialization instead of the random one.
+func NewModelWithInit(input image.Image, numPolygons int, seed int64, bgColor Color, kind InitKind) (*Model, error) {
+	m := NewModel(input, numPolygons, seed, bgColor)
+	if kind == RandomInit {
+		return m, nil
+	}
+	if err := m.Initialize(kind); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Initialize replaces the polygons with NumPolygons new ones created by the
+// given initialization and rescores the model.
+func (m *Model) Initialize(kind InitKind) error {
+	switch kind {
+	case RandomInit:
+		m.initializeRandom()
+	case ErrorInit:
+		m.Polygons = nil
+		m.prepare()
+		residual := m.newResidualMap()
+		for len(m.Polygons) < m.NumPolygons {
+			// the first polygons cover large areas and the last ones add
+			// detail, like the growth does
+			progress := float64(len(m.Polygons)) / float64(m.NumPolygons)
+			polygon := m.newResidualPolygon(residual, m.side()*(0.5-0.375*progress))
+			polygon.Color = m.meanColor(polygon)
+			polygons := make(Polygons, len(m.Polygons), len(m.Polygons)+1)
+			copy(polygons, m.Polygons)
+			polygons = append(polygons, polygon)
+			m.commit(m.scoreRegion(polygons, polygon.bounds()))
+			residual.update(polygon.bounds())
+		}
+	case DelaunayInit:
+		return m.initializeDelaunay()
+	default:
+		return fmt.Errorf("unknown initialization %q", kind)
+	}
+	return nil
+}
+
+// initializeRandom creates NumPolygons random polygons, like NewModel does.
+func (m *Model) initializeRandom() {
+	mt := m.mutator()
+	m.Polygons = nil
+	for i := 0; i < m.NumPolygons; i++ {
+		m.Polygons = append(m.Polygons, mt.newRandomPolygon())
+	}
+	m.prepare()
+}
+
+// initializeDelaunay triangulates the corners of the image and points
+// sampled where the Sobel gradient is the strongest. A triangulation of n
+// points with 4 of them on the convex hull has 2n-6 triangles, so about
+// NumPolygons triangles are created. NumPolygons is raised if needed so every
+// triangle is kept, otherwise the background would show through the gaps.
+func (m *Model) initializeDelaunay() error {
+	edges, err := ImportanceMap(SobelImportance, m.TargetImage)
+	if err != nil {
+		return err
+	}
+	s := newSampler(edges)
+	// the corners are on the outer edges of the corner pixels, so the
+	// triangles cover the center of every pixel
+	w, h := float64(m.Width)-0.5, float64(m.Height)-0.5
+	points := []Point{{-0.5, -0.5}, {w, -0.5}, {-0.5, h}, {w, h}}
+	for len(points) < 4+(m.NumPolygons-2)/2 {
+		points = append(points, s.point(m.Width, m.Height))
+	}
+
+	m.Polygons = nil
+	for _, t := range delaunay(points) {
+		polygon := Polygon{
+			Color:    m.Alpha.clamp(Color{A: 255}),
+			Vertices: []Point{points[t[0]], points[t[1]], points[t[2]]},
+		}
+		polygon.Color = m.meanColor(polygon)
+		m.Polygons = append(m.Polygons, polygon)
+	}
+	if len(m.Polygons) > m.NumPolygons {
+		m.NumPolygons = len(m.Polygons)
+	}
+	m.prepare()
+	return nil
+}
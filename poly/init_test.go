@@ -0,0 +1,36 @@
+package poly
+
+import (
+	"testing"
+)
+
+func TestErrorInit(t *testing.T) {
+	img := decodeTestImage(t)
+	random, err := NewModelWithInit(img, 30, 1, Color{255, 255, 255, 255}, RandomInit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guided, err := NewModelWithInit(img, 30, 1, Color{255, 255, 255, 255}, ErrorInit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guided.Polygons) != 30 {
+		t.Fatalf("error initialization created %v polygons, want 30", len(guided.Polygons))
+	}
+	if guided.Score >= random.Score {
+		t.Errorf("error initialization score %v, random initialization %v", guided.Score, random.Score)
+	}
+	checkIncrementalScore(t, guided, guided.Score)
+
+	if _, err := NewModelWithInit(img, 30, 1, Color{}, "spiral"); err == nil {
+		t.Errorf("unknown initializations should fail")
+	}
+}
+
+func TestMeanColor(t *testing.T) {
+	model := NewModel(uniformImage(20, 20, Color{30, 60, 90, 255}), 1, 1, Color{255, 255, 255, 255})
+	polygon := Polygon{Color: Color{A: 75}, Vertices: []Point{{2, 2}, {17, 3}, {9, 16}}}
+	if got, want := model.meanColor(polygon), (Color{30, 60, 90, 75}); got != want {
+		t.Errorf("mean color %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package poly
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	CIE76Metric     MetricKind = "cie76"
+	CIEDE2000Metric MetricKind = "ciede2000"
+)
+
+func init() {
+	metrics[CIE76Metric] = func(target *image.RGBA, w weights) Metric {
+		return newDeltaEMetric(target, w, deltaE76)
+	}
+	metrics[CIEDE2000Metric] = func(target *image.RGBA, w weights) Metric {
+		return newDeltaEMetric(target, w, deltaE2000)
+	}
+}
+
+// lab is a color in the CIELAB color space with the D65 white point.
+type lab struct {
+	L, A, B float64
+}
+
+// linearRGB maps every sRGB channel value to its linear intensity.
+var linearRGB = func() [256]float64 {
+	var table [256]float64
+	for i := range table {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			table[i] = c / 12.92
+		} else {
+			table[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+func rgbToLab(r, g, b uint8) lab {
+	lr, lg, lb := linearRGB[r], linearRGB[g], linearRGB[b]
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// deltaE76 is the euclidean distance between two colors.
+func deltaE76(c1, c2 lab) float64 {
+	dL, dA, dB := c1.L-c2.L, c1.A-c2.A, c1.B-c2.B
+	return math.Sqrt(dL*dL + dA*dA + dB*dB)
+}
+
+// deltaE2000 is the CIEDE2000 color difference with unit weighting factors.
+// See: Sharma, Wu and Dalal, "The CIEDE2000 Color-Difference Formula"
+func deltaE2000(c1, c2 lab) float64 {
+	const pow25to7 = 6103515625.0 // 25^7
+	rad := math.Pi / 180
+
+	cBar := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25to7)))
+	a1, a2 := (1+g)*c1.A, (1+g)*c2.A
+	cp1, cp2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+	hp1, hp2 := hueAngle(c1.B, a1), hueAngle(c2.B, a2)
+
+	dL := c2.L - c1.L
+	dC := cp2 - cp1
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(dh/2*rad)
+
+	lBar := (c1.L + c2.L) / 2
+	cpBar := (cp1 + cp2) / 2
+	hBar := hp1 + hp2
+	if cp1*cp2 != 0 {
+		if math.Abs(hp1-hp2) <= 180 {
+			hBar /= 2
+		} else if hBar < 360 {
+			hBar = (hBar + 360) / 2
+		} else {
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos((hBar-30)*rad) + 0.24*math.Cos(2*hBar*rad) +
+		0.32*math.Cos((3*hBar+6)*rad) - 0.20*math.Cos((4*hBar-63)*rad)
+	dTheta := 30 * math.Exp(-((hBar-275)/25)*((hBar-275)/25))
+	cpBar7 := math.Pow(cpBar, 7)
+	rc := 2 * math.Sqrt(cpBar7/(cpBar7+pow25to7))
+	l50 := (lBar - 50) * (lBar - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cpBar
+	sh := 1 + 0.015*cpBar*t
+	rt := -math.Sin(2*dTheta*rad) * rc
+
+	l, c, h := dL/sl, dC/sc, dH/sh
+	return math.Sqrt(l*l + c*c + h*h + rt*c*h)
+}
+
+// hueAngle returns the angle of (a, b) in degrees in the range [0, 360).
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// deltaEMetric is the mean color difference between the pixels of two images
+// in CIELAB. The target is converted only once, when the metric is created.
+type deltaEMetric struct {
+	target   *image.RGBA
+	lab      []lab
+	weights  weights
+	total    int
+	distance func(c1, c2 lab) float64
+}
+
+func newDeltaEMetric(target *image.RGBA, w weights, distance func(c1, c2 lab) float64) deltaEMetric {
+	bounds := target.Rect
+	colors := make([]lab, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		p := target.PixOffset(bounds.Min.X, y)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colors = append(colors, rgbToLab(target.Pix[p], target.Pix[p+1], target.Pix[p+2]))
+			p += 4
+		}
+	}
+	return deltaEMetric{target, colors, w, w.total(bounds), distance}
+}
+
+func (d deltaEMetric) Error(candidate *image.RGBA, r image.Rectangle) float64 {
+	bounds := d.target.Rect
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := (y-bounds.Min.Y)*bounds.Dx() + r.Min.X - bounds.Min.X
+		c := candidate.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			e := d.distance(d.lab[i], rgbToLab(candidate.Pix[c], candidate.Pix[c+1], candidate.Pix[c+2]))
+			sum += e * float64(d.weights.at(x, y))
+			i++
+			c += 4
+		}
+	}
+	return sum
+}
+
+func (d deltaEMetric) Score(err float64) float64 {
+	return err / float64(d.total)
+}
@@ -0,0 +1,85 @@
+package poly
+
+import (
+	"encoding/gob"
+	"fmt"
+	"image"
+	"os"
+)
+
+// legacyPoint is the integer point of the models saved before vertices had
+// sub-pixel coordinates.
+type legacyPoint struct {
+	X, Y int
+}
+
+type legacyPolygon struct {
+	Color    Color
+	Vertices []legacyPoint
+}
+
+// legacyModel mirrors the fields of Model as they were saved with integer
+// vertices. It must not change, files written since then decode into Model
+// directly.
+type legacyModel struct {
+	Width, Height                 int
+	TargetImage                   *image.RGBA
+	NumPolygons                   int
+	Polygons                      []legacyPolygon
+	Scale                         float64
+	OriginalWidth, OriginalHeight int
+	Score                         float64
+	Iteration                     int
+	BackgroundColor               Color
+	MutateVertexProbability       float64
+	Annealing                     *Schedule
+	Population                    *Population
+	FillRule                      FillRule
+	AntiAlias                     bool
+	Metric                        MetricKind
+	ReportMetric                  MetricKind
+	Mask                          *image.Gray
+}
+
+// readLegacyGob decodes a model saved with integer vertices.
+func readLegacyGob(filePath string, m *Model) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to open file: %w", err)
+	}
+	defer file.Close()
+
+	var legacy legacyModel
+	if err := gob.NewDecoder(file).Decode(&legacy); err != nil {
+		return fmt.Errorf("unable to decode file: %w", err)
+	}
+
+	*m = Model{
+		Width:                   legacy.Width,
+		Height:                  legacy.Height,
+		TargetImage:             legacy.TargetImage,
+		NumPolygons:             legacy.NumPolygons,
+		Scale:                   legacy.Scale,
+		OriginalWidth:           legacy.OriginalWidth,
+		OriginalHeight:          legacy.OriginalHeight,
+		Score:                   legacy.Score,
+		Iteration:               legacy.Iteration,
+		BackgroundColor:         legacy.BackgroundColor,
+		MutateVertexProbability: legacy.MutateVertexProbability,
+		Annealing:               legacy.Annealing,
+		Population:              legacy.Population,
+		FillRule:                legacy.FillRule,
+		AntiAlias:               legacy.AntiAlias,
+		Metric:                  legacy.Metric,
+		ReportMetric:            legacy.ReportMetric,
+		Mask:                    legacy.Mask,
+	}
+	for _, p := range legacy.Polygons {
+		polygon := Polygon{Color: p.Color}
+		for _, v := range p.Vertices {
+			polygon.Vertices = append(polygon.Vertices, Point{float64(v.X), float64(v.Y)})
+		}
+		m.Polygons = append(m.Polygons, polygon)
+	}
+	return nil
+}
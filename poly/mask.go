@@ -0,0 +1,66 @@
+package poly
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// weights is the importance of every pixel of the target image, from 0 to
+// 255, taken from a grayscale mask. Without a mask every pixel weights 255.
+type weights struct {
+	mask *image.Gray
+}
+
+func (w weights) at(x, y int) int {
+	if w.mask == nil {
+		return 255
+	}
+	return int(w.mask.Pix[w.mask.PixOffset(x, y)])
+}
+
+// total returns the sum of the weights of the pixels inside r.
+func (w weights) total(r image.Rectangle) int {
+	if w.mask == nil {
+		return 255 * r.Dx() * r.Dy()
+	}
+	sum := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += w.at(x, y)
+		}
+	}
+	return sum
+}
+
+func imageToGray(src image.Image) *image.Gray {
+	dst := image.NewGray(src.Bounds())
+	draw.Draw(dst, dst.Rect, src, src.Bounds().Min, draw.Src)
+	return dst
+}
+
+// SetMask sets the importance mask of the model. The error of every pixel is
+// weighted by the brightness of the mask, so black areas are ignored and
+// white ones get the most detail. The mask must be the same size as the
+// target image, a nil mask weights every pixel the same.
+func (m *Model) SetMask(mask image.Image) error {
+	var gray *image.Gray
+	if mask != nil {
+		if mask.Bounds().Size() != m.TargetImage.Rect.Size() {
+			return fmt.Errorf("mask size %v does not match image size %v", mask.Bounds().Size(), m.TargetImage.Rect.Size())
+		}
+		gray = imageToGray(mask)
+		gray.Rect = m.TargetImage.Rect
+		if (weights{gray}).total(gray.Rect) == 0 {
+			return errors.New("mask is completely black")
+		}
+	}
+	m.Mask = gray
+	m.Importance = ""
+	m.metric = nil
+	m.reportMetric = nil
+	m.sampler = nil
+	m.prepare()
+	return nil
+}
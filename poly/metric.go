@@ -0,0 +1,193 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+type MetricKind string
+
+const (
+	// MSEMetric is the mean squared error of the color channels and the
+	// default metric of every model
+	MSEMetric  MetricKind = "mse"
+	RMSEMetric MetricKind = "rmse"
+	// PSNRMetric is the peak signal-to-noise ratio in decibels, negated so
+	// that lower scores are better like with the other metrics
+	PSNRMetric MetricKind = "psnr"
+	MAEMetric  MetricKind = "mae"
+)
+
+// Metric measures how different a candidate image is from the target image it
+// was created for. The error of an image is the sum of the errors of its
+// regions, so candidates can be scored by only comparing the pixels that
+// changed.
+type Metric interface {
+	// Error returns the contribution of the pixels inside r to the error.
+	Error(candidate *image.RGBA, r image.Rectangle) float64
+	// Score converts the error of the whole image into a score, lower
+	// scores are better.
+	Score(err float64) float64
+}
+
+// aligner is implemented by metrics whose error for a pixel depends on its
+// neighbours. align returns the region whose pixels are needed to compute the
+// error of r, the same region must be used when comparing two candidates.
+type aligner interface {
+	align(r image.Rectangle) image.Rectangle
+}
+
+var metrics = map[MetricKind]func(target *image.RGBA, w weights) Metric{
+	MSEMetric: func(target *image.RGBA, w weights) Metric {
+		return newSquaredErrorMetric(MSEMetric, target, w)
+	},
+	RMSEMetric: func(target *image.RGBA, w weights) Metric {
+		return newSquaredErrorMetric(RMSEMetric, target, w)
+	},
+	PSNRMetric: func(target *image.RGBA, w weights) Metric {
+		return newSquaredErrorMetric(PSNRMetric, target, w)
+	},
+	MAEMetric: func(target *image.RGBA, w weights) Metric {
+		return absoluteErrorMetric{target, w, w.total(target.Rect)}
+	},
+}
+
+// NewMetric returns the metric of the given kind comparing images with the
+// target, weighting every pixel by the mask when it is not nil. An empty kind
+// returns the default metric.
+func NewMetric(kind MetricKind, target *image.RGBA, mask *image.Gray) (Metric, error) {
+	if kind == "" {
+		kind = MSEMetric
+	}
+	newMetric, ok := metrics[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", kind)
+	}
+	return newMetric(target, weights{mask}), nil
+}
+
+// squaredErrorMetric accumulates the squared differences of the color
+// channels, which is shared by MSE, RMSE and PSNR.
+type squaredErrorMetric struct {
+	kind    MetricKind
+	target  *image.RGBA
+	weights weights
+	// total is the sum of the weights of the target pixels
+	total int
+}
+
+func newSquaredErrorMetric(kind MetricKind, target *image.RGBA, w weights) squaredErrorMetric {
+	return squaredErrorMetric{kind, target, w, w.total(target.Rect)}
+}
+
+func (s squaredErrorMetric) Error(candidate *image.RGBA, r image.Rectangle) float64 {
+	sum := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		t := s.target.PixOffset(r.Min.X, y)
+		c := candidate.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// alpha is skipped, the rendered images are always opaque
+			pixel := 0
+			for i := 0; i < 3; i++ {
+				d := absoluteDifferenceInt8(s.target.Pix[t+i], candidate.Pix[c+i])
+				pixel += d * d
+			}
+			sum += pixel * s.weights.at(x, y)
+			t += 4
+			c += 4
+		}
+	}
+	return float64(sum)
+}
+
+func (s squaredErrorMetric) Score(err float64) float64 {
+	mse := err / float64(3*s.total)
+	switch s.kind {
+	case RMSEMetric:
+		return math.Sqrt(mse)
+	case PSNRMetric:
+		return 10 * math.Log10(mse/(255*255))
+	default:
+		return mse
+	}
+}
+
+// absoluteErrorMetric is the mean absolute error of the color channels.
+type absoluteErrorMetric struct {
+	target  *image.RGBA
+	weights weights
+	total   int
+}
+
+func (a absoluteErrorMetric) Error(candidate *image.RGBA, r image.Rectangle) float64 {
+	sum := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		t := a.target.PixOffset(r.Min.X, y)
+		c := candidate.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			pixel := 0
+			for i := 0; i < 3; i++ {
+				pixel += absoluteDifferenceInt8(a.target.Pix[t+i], candidate.Pix[c+i])
+			}
+			sum += pixel * a.weights.at(x, y)
+			t += 4
+			c += 4
+		}
+	}
+	return float64(sum)
+}
+
+func (a absoluteErrorMetric) Score(err float64) float64 {
+	return err / float64(3*a.total)
+}
+
+// checkMetrics returns an error when the model refers to a metric this
+// build does not know, like a GOB file written by a newer version.
+func (m *Model) checkMetrics() error {
+	for _, kind := range []MetricKind{m.Metric, m.ReportMetric} {
+		if _, ok := metrics[kind]; kind != "" && !ok {
+			return fmt.Errorf("unknown metric %q", kind)
+		}
+	}
+	return nil
+}
+
+// SetMetric changes the metric used to score the model and rescores it.
+func (m *Model) SetMetric(kind MetricKind) error {
+	metric, err := NewMetric(kind, m.TargetImage, m.Mask)
+	if err != nil {
+		return err
+	}
+	m.Metric = kind
+	m.metric = metric
+	m.prepare()
+	return nil
+}
+
+// SetReportMetric changes the metric logged by Optimize next to the score.
+func (m *Model) SetReportMetric(kind MetricKind) error {
+	metric, err := NewMetric(kind, m.TargetImage, m.Mask)
+	if err != nil {
+		return err
+	}
+	m.ReportMetric = kind
+	m.reportMetric = metric
+	return nil
+}
+
+// Report returns the score of the model polygons using the report metric.
+func (m *Model) Report() float64 {
+	if m.reportMetric == nil {
+		metric, err := NewMetric(m.ReportMetric, m.TargetImage, m.Mask)
+		if err != nil {
+			panic(err)
+		}
+		m.reportMetric = metric
+	}
+	rgba := m.canvas
+	if rgba == nil {
+		rgba = polygonsToRGBA(m.Polygons, m.BackgroundColor, m.FillRule, m.AntiAlias, m.Width, m.Height)
+	}
+	return m.reportMetric.Score(m.reportMetric.Error(rgba, rgba.Rect))
+}
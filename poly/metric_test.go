@@ -0,0 +1,144 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func uniformImage(w, h int, c Color) *image.RGBA {
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i] = c.R
+		rgba.Pix[i+1] = c.G
+		rgba.Pix[i+2] = c.B
+		rgba.Pix[i+3] = c.A
+	}
+	return rgba
+}
+
+func TestMetrics(t *testing.T) {
+	target := uniformImage(8, 4, Color{100, 100, 100, 255})
+	// alpha differences must be ignored
+	candidate := uniformImage(8, 4, Color{110, 90, 100, 0})
+
+	tests := []struct {
+		kind MetricKind
+		want float64
+	}{
+		{MSEMetric, 200.0 / 3},
+		{RMSEMetric, math.Sqrt(200.0 / 3)},
+		{PSNRMetric, 10 * math.Log10(200.0/3/(255*255))},
+		{MAEMetric, 20.0 / 3},
+	}
+	for _, test := range tests {
+		metric, err := NewMetric(test.kind, target, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", test.kind, err)
+		}
+		got := metric.Score(metric.Error(candidate, candidate.Rect))
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: got %v, want %v", test.kind, got, test.want)
+		}
+	}
+}
+
+func TestMetricErrorIsAdditive(t *testing.T) {
+	target := uniformImage(8, 4, Color{100, 100, 100, 255})
+	candidate := uniformImage(8, 4, Color{0, 50, 200, 255})
+	left, right := image.Rect(0, 0, 3, 4), image.Rect(3, 0, 8, 4)
+	for kind := range metrics {
+		metric, _ := NewMetric(kind, target, nil)
+		if _, ok := metric.(aligner); ok {
+			// only additive over aligned regions
+			continue
+		}
+		whole := metric.Error(candidate, candidate.Rect)
+		parts := metric.Error(candidate, left) + metric.Error(candidate, right)
+		if math.Abs(whole-parts) > 1e-9 {
+			t.Errorf("%v: whole image error %v, sum of regions %v", kind, whole, parts)
+		}
+	}
+}
+
+func TestNewMetricUnknown(t *testing.T) {
+	if _, err := NewMetric("foo", uniformImage(1, 1, Color{}), nil); err == nil {
+		t.Errorf("expected an error for an unknown metric")
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	target := uniformImage(20, 12, Color{100, 100, 100, 255})
+	for i := 0; i < len(target.Pix); i += 12 {
+		target.Pix[i] = 200
+	}
+	for _, kind := range []MetricKind{BlockSSIMMetric, BlockMSSSIMMetric} {
+		metric, _ := NewMetric(kind, target, nil)
+		if got := metric.Score(metric.Error(target, target.Rect)); math.Abs(got) > 1e-9 {
+			t.Errorf("%v: identical images scored %v", kind, got)
+		}
+		flat := uniformImage(20, 12, Color{100, 100, 100, 255})
+		got := metric.Score(metric.Error(flat, flat.Rect))
+		if got <= 0 || got > 2 {
+			t.Errorf("%v: different images scored %v", kind, got)
+		}
+	}
+}
+
+func TestDeltaE2000(t *testing.T) {
+	// reference pairs from Sharma, Wu and Dalal
+	tests := []struct {
+		c1, c2 lab
+		want   float64
+	}{
+		{lab{50, 2.6772, -79.7751}, lab{50, 0, -82.7485}, 2.0425},
+		{lab{50, 3.1571, -77.2803}, lab{50, 0, -82.7485}, 2.8615},
+		{lab{50, 0, 0}, lab{50, -1, 2}, 2.3669},
+		{lab{50, 2.5, 0}, lab{61, -5, 29}, 22.8977},
+		{lab{50, 2.5, 0}, lab{73, 25, -18}, 27.1492},
+		{lab{2.0776, 0.0795, -1.1350}, lab{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for _, test := range tests {
+		if got := deltaE2000(test.c1, test.c2); math.Abs(got-test.want) > 1e-4 {
+			t.Errorf("deltaE2000(%v, %v) = %v, want %v", test.c1, test.c2, got, test.want)
+		}
+	}
+}
+
+func TestRGBToLab(t *testing.T) {
+	white := rgbToLab(255, 255, 255)
+	if math.Abs(white.L-100) > 1e-3 || math.Abs(white.A) > 1e-3 || math.Abs(white.B) > 1e-3 {
+		t.Errorf("white is %v in CIELAB", white)
+	}
+	red := rgbToLab(255, 0, 0)
+	if math.Abs(red.L-53.24) > 0.01 || math.Abs(red.A-80.09) > 0.01 || math.Abs(red.B-67.20) > 0.01 {
+		t.Errorf("red is %v in CIELAB", red)
+	}
+}
+
+func TestMaskedMetrics(t *testing.T) {
+	target := uniformImage(16, 16, Color{100, 100, 100, 255})
+	candidate := uniformImage(16, 16, Color{100, 100, 100, 255})
+	// the candidate is only wrong on the right half, which the mask ignores
+	mask := image.NewGray(target.Rect)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				mask.Pix[mask.PixOffset(x, y)] = 255
+			} else {
+				candidate.Pix[candidate.PixOffset(x, y)] = 0
+			}
+		}
+	}
+	for kind := range metrics {
+		if kind == PSNRMetric || kind == BlockMSSSIMMetric {
+			// PSNR of identical images is -Inf and the coarse windows
+			// of block MS-SSIM span both halves
+			continue
+		}
+		metric, _ := NewMetric(kind, target, mask)
+		if got := metric.Score(metric.Error(candidate, candidate.Rect)); math.Abs(got) > 1e-9 {
+			t.Errorf("%v: masked out differences scored %v", kind, got)
+		}
+	}
+}
@@ -14,17 +14,60 @@ import (
 )
 
 type Model struct {
-	Width, Height           int
-	TargetImage             *image.RGBA
-	NumPolygons             int
-	Polygons                Polygons
-	Scale                   float64
-	Score                   float64
-	Iteration               int
-	BackgroundColor         Color
-	MutateVertexProbability float64
+	Width, Height int
+	TargetImage   *image.RGBA
+	// NumPolygons is the polygon budget, there can be fewer Polygons while
+	// they grow or after Prune
+	NumPolygons int
+	Polygons    Polygons
+	Scale       float64
+	// OriginalWidth and OriginalHeight are the size of the input image
+	// before it was scaled down for the optimization, if known
+	OriginalWidth, OriginalHeight int
+	Score                         float64
+	Iteration                     int
+	BackgroundColor               Color
+	MutateVertexProbability       float64
+	// Annealing enables simulated annealing when set, otherwise Optimize
+	// only accepts candidates that improve the score
+	Annealing *Schedule
+	// Population switches Optimize to a genetic algorithm when set
+	Population *Population
+	// Mutations configures the mutation operators, DefaultMutations when nil
+	Mutations *Mutations
+	// Alpha bounds the alpha of the polygons, see SetAlpha
+	Alpha *AlphaRange
+	// Growth adds polygons during Optimize up to NumPolygons when set
+	Growth   *Growth
+	FillRule FillRule
+	// AntiAlias renders the polygons with anti-aliasing during the
+	// optimization, which is more accurate but slower. Exports are always
+	// anti-aliased.
+	AntiAlias bool
+	// Metric is the kind of metric used to compare the polygons with the
+	// target image, MSE when empty
+	Metric MetricKind
+	// ReportMetric is an additional metric only logged during Optimize
+	ReportMetric MetricKind
+	// Mask weights the error of every pixel when set, see SetMask
+	Mask *image.Gray
+	// Importance is the kind of importance map Mask was computed from, see
+	// SetImportance. Only computed masks make new vertices more likely where
+	// they are brighter.
+	Importance ImportanceKind
+
+	metric       Metric
+	reportMetric Metric
+	sampler      *sampler
+	strength     strength
+	// canvas is the rendering of Polygons and err its error, they are used
+	// to score candidates incrementally
+	canvas *image.RGBA
+	err    float64
 }
 
+// NewModel creates a model of the input image with numPolygons random
+// polygons, NewModelWithInit creates them with another initialization.
 func NewModel(input image.Image, numPolygons int, seed int64, bgColor Color) *Model {
 	rand.Seed(seed)
 	bounds := input.Bounds()
@@ -39,75 +82,173 @@ func NewModel(input image.Image, numPolygons int, seed int64, bgColor Color) *Mo
 		BackgroundColor: bgColor,
 	}
 
-	for i := 0; i < numPolygons; i++ {
-		order := rand.Intn(3) + 3
-		polygon := newRandomPolygon(order, m.Width, m.Height)
-		m.Polygons = append(m.Polygons, polygon)
-	}
-
-	rgbaCandidate := polygonsToRGBA(m.Polygons, m.BackgroundColor, m.Width, m.Height)
-	m.Score = mse(m.TargetImage, rgbaCandidate)
+	m.initializeRandom()
 
 	return &m
 }
 
-func (m *Model) mutate() Polygons {
-	polygons := m.Polygons.clone()
-	randomIndex := rand.Intn(m.NumPolygons)
-	r := rand.Float64()
-	polygons[randomIndex].mutate(r, m.Width, m.Height)
-	return polygons
+// Rescore renders the polygons from scratch and updates the score. It should
+// be called after changing any field that affects how the model is scored.
+func (m *Model) Rescore() {
+	m.prepare()
 }
 
-func (m *Model) step(id int, jobs <-chan int, results chan<- float64) {
-	for j := range jobs {
-		polygons := m.mutate()
-		rgbaCandidate := polygonsToRGBA(polygons, m.BackgroundColor, m.Width, m.Height)
+// mutate returns a copy of the polygons with a random mutation and the
+// rectangle containing every pixel affected by the mutation. Only a mutated
+// polygon is cloned, the rest share their vertices with the model.
+func (m *Model) mutate() (Polygons, image.Rectangle) {
+	polygons := make(Polygons, len(m.Polygons))
+	copy(polygons, m.Polygons)
+	dirty := m.mutator().mutate(polygons, rand.Float64())
+	return polygons, dirty
+}
 
-		newScore := mse(m.TargetImage, rgbaCandidate)
-		if newScore < m.Score {
-			m.Polygons = polygons
-			m.Score = newScore
-			m.Iteration = j
-			results <- newScore
+// candidate is a mutated copy of the model polygons together with its score.
+type candidate struct {
+	polygons Polygons
+	score    float64
+	// region is the rendering of the pixels that changed and err the error
+	// of the whole image, only set for incremental candidates
+	region *image.RGBA
+	err    float64
+}
+
+// newCandidate mutates a copy of the current polygons and scores it. It only
+// reads the model, so several candidates can be built concurrently.
+func (m *Model) newCandidate() candidate {
+	return m.scoreRegion(m.mutate())
+}
+
+// score renders the given polygons and compares them with the target image.
+func (m *Model) score(polygons Polygons) candidate {
+	rgbaCandidate := polygonsToRGBA(polygons, m.BackgroundColor, m.FillRule, m.AntiAlias, m.Width, m.Height)
+	return candidate{
+		polygons: polygons,
+		score:    m.metric.Score(m.metric.Error(rgbaCandidate, rgbaCandidate.Rect)),
+	}
+}
+
+// generation builds and scores n candidates in parallel from the same
+// snapshot of the model and returns the best of them.
+func (m *Model) generation(n int) candidate {
+	candidates := make([]candidate, n)
+	parallel(n, n, func(i int) {
+		candidates[i] = m.newCandidate()
+	})
+
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.score < best.score {
+			best = c
 		}
-		results <- math.Inf(1)
 	}
+	return best
 }
 
+// Validate checks that the optimization settings of the model can be used
+// together.
+func (m *Model) Validate() error {
+	if m.Population != nil {
+		if m.Annealing != nil {
+			return fmt.Errorf("annealing and population are exclusive")
+		}
+		if err := m.Population.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Optimize runs the given number of generations. Every generation evaluates
+// concurrency candidates in parallel and only the best one is committed to
+// the model, so the model is never written while candidates are being built.
 func (m *Model) Optimize(iterations, concurrency, logFrequency int) float64 {
-	var score float64
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if m.canvas == nil {
+		m.prepare()
+	}
+	if m.Population != nil {
+		if m.Annealing != nil {
+			fmt.Println("annealing is ignored by the genetic algorithm")
+		}
+		return m.evolve(iterations, concurrency, logFrequency)
+	}
 	var successful int
-
-	jobs := make(chan int, iterations)
-	results := make(chan float64, iterations)
-
-	for w := 1; w <= concurrency; w++ {
-		go m.step(w, jobs, results)
+	var annealing *annealer
+	if m.Annealing != nil {
+		annealing = newAnnealer(m.Annealing)
 	}
+	// with annealing the model can move to worse states, so the best state
+	// seen during the run is restored at the end
+	bestPolygons, bestScore := m.Polygons, m.Score
+	restore := false
 
-	for i := 1; i <= iterations; i++ {
-		jobs <- i
+	var growing *grower
+	if m.Growth != nil {
+		growing = &grower{growth: m.Growth}
 	}
-	close(jobs)
 
-	for a := 1; a <= iterations; a++ {
-		current := <-results
-		if current < score || a == 1 {
-			score = current
+	improved := false
+	for i := 1; i <= iterations; i++ {
+		if growing != nil && len(m.Polygons) < m.NumPolygons && (len(m.Polygons) == 0 || growing.due(i, improved)) {
+			m.grow()
+			// the new polygon can make the score worse, it is part of the
+			// model anyway
+			bestPolygons, bestScore = m.Polygons, m.Score
+		}
+		best := m.generation(concurrency)
+		accepted := best.score < m.Score
+		if annealing != nil {
+			annealing.cool(i, iterations, improved)
+			accepted = annealing.accept(m.Score, best.score)
+		}
+		improved = best.score < m.Score
+		m.strength.update(improved, m.mutations().MinStrength)
+		if accepted {
+			m.commit(best)
 			successful++
 		}
-		if a%logFrequency == 0 {
-			fmt.Printf("%v,%v,%v,%v\n", time.Now().Format(time.RFC3339), a, score, successful)
+		restore = m.Score > bestScore
+		if !restore {
+			bestPolygons, bestScore = m.Polygons, m.Score
+		}
+		m.Iteration++
+		if logFrequency > 0 && i%logFrequency == 0 {
+			m.logProgress(successful, annealing)
 		}
 	}
+	if restore {
+		m.Polygons = bestPolygons
+		m.prepare()
+	}
 
 	fmt.Printf("successful iterations: %v\n", successful)
 
-	return score
+	return m.Score
 }
 
-func polygonsToRGBA(polygons Polygons, bgColor Color, w, h int) *image.RGBA {
+// logProgress prints a CSV line with the time, the iteration, the score and
+// the number of successful iterations, followed by the temperature when
+// annealing and the report metric when set.
+func (m *Model) logProgress(successful int, annealing *annealer) {
+	fields := []string{
+		time.Now().Format(time.RFC3339),
+		fmt.Sprint(m.Iteration),
+		fmt.Sprint(m.Score),
+		fmt.Sprint(successful),
+	}
+	if annealing != nil {
+		fields = append(fields, fmt.Sprint(annealing.temperature))
+	}
+	if m.ReportMetric != "" {
+		fields = append(fields, fmt.Sprint(m.Report()))
+	}
+	fmt.Println(strings.Join(fields, ","))
+}
+
+func polygonsToRGBA(polygons Polygons, bgColor Color, rule FillRule, antialias bool, w, h int) *image.RGBA {
 	rect := image.Rect(0, 0, w, h)
 	rgba := image.NewRGBA(rect)
 
@@ -120,8 +261,12 @@ func polygonsToRGBA(polygons Polygons, bgColor Color, w, h int) *image.RGBA {
 		rgba.Pix[i+3] = bgColor.A
 	}
 
+	if antialias {
+		renderAA(polygons, rule, rgba)
+		return rgba
+	}
 	for _, polygon := range polygons {
-		rasterizePolygonWWN(polygon, rgba)
+		rasterizePolygon(polygon, rule, rgba)
 	}
 
 	return rgba
@@ -143,6 +288,8 @@ func (m *Model) GOB(filePath string) error {
 	return nil
 }
 
+// ReadGob decodes a file written by GOB into object. Models saved with
+// integer vertices are converted to the current format.
 func ReadGob(filePath string, object interface{}) error {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -153,25 +300,59 @@ func ReadGob(filePath string, object interface{}) error {
 	decoder := gob.NewDecoder(file)
 	err = decoder.Decode(object)
 	if err != nil {
+		if m, ok := object.(*Model); ok {
+			if legacyErr := readLegacyGob(filePath, m); legacyErr == nil {
+				return nil
+			}
+		}
 		return fmt.Errorf("unable to decode file: %w", err)
 	}
+	if m, ok := object.(*Model); ok {
+		return m.checkMetrics()
+	}
 
 	return nil
 }
 
+// OutputSize returns the size of the original input image, or the size the
+// model is optimized at when it is unknown.
+func (m *Model) OutputSize() (int, int) {
+	if m.OriginalWidth > 0 && m.OriginalHeight > 0 {
+		return m.OriginalWidth, m.OriginalHeight
+	}
+	scale := m.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	return int(math.Round(float64(m.Width) / scale)), int(math.Round(float64(m.Height) / scale))
+}
+
+// SVG returns the polygons as an SVG image of the output size.
 func (m *Model) SVG() string {
+	width, height := m.OutputSize()
+	return m.SVGAt(width, height)
+}
+
+// SVGAt returns the polygons as an SVG image of the given size.
+func (m *Model) SVGAt(width, height int) string {
 	bg := m.BackgroundColor
+	sx := float64(width) / float64(m.Width)
+	sy := float64(height) / float64(m.Height)
 	var lines []string
-	lines = append(lines, fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%d\" height=\"%d\">", 2*m.Width, 2*m.Height))
-	lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" />", 2*m.Width, 2*m.Height, bg.R, bg.G, bg.B))
-	lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f) translate(0.5 0.5)\">", 2*m.Scale))
+	lines = append(lines, fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%d\" height=\"%d\">", width, height))
+	lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" />", width, height, bg.R, bg.G, bg.B))
+	if m.FillRule == EvenOdd {
+		lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f %f) translate(0.5 0.5)\" fill-rule=\"evenodd\">", sx, sy))
+	} else {
+		lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f %f) translate(0.5 0.5)\">", sx, sy))
+	}
 	for _, polygon := range m.Polygons {
 		color := polygon.Color
 		attrs := "<polygon fill=\"#%02x%02x%02x\" fill-opacity=\"%f\""
 		attrs = fmt.Sprintf(attrs, color.R, color.G, color.B, float64(color.A)/255)
 		p := " points=\""
 		for _, vertex := range polygon.Vertices {
-			p = p + strconv.Itoa(vertex.X) + "," + strconv.Itoa(vertex.Y) + " "
+			p = p + formatCoordinate(vertex.X) + "," + formatCoordinate(vertex.Y) + " "
 		}
 		p = p + "\"" + "/>"
 		attrs = attrs + p
@@ -182,13 +363,27 @@ func (m *Model) SVG() string {
 	return strings.Join(lines, "\n")
 }
 
+// formatCoordinate writes a vertex coordinate with up to two decimals, which
+// is below a hundredth of a pixel of the model.
+func formatCoordinate(c float64) string {
+	return strconv.FormatFloat(math.Round(c*100)/100, 'f', -1, 64)
+}
+
+// PNG saves the polygons as a PNG image of the output size.
 func (m *Model) PNG(fname string) error {
+	width, height := m.OutputSize()
+	return m.PNGAt(fname, width, height)
+}
+
+// PNGAt saves the polygons as a PNG image of the given size.
+func (m *Model) PNGAt(fname string, width, height int) error {
 	file, err := os.Create(fname)
 	if err != nil {
 		return fmt.Errorf("unable to create file: %w", err)
 	}
+	defer file.Close()
 
-	rgbaImage := polygonsToRGBA(m.Polygons, m.BackgroundColor, m.Width, m.Height)
+	rgbaImage := m.RenderAt(width, height)
 
 	err = png.Encode(file, rgbaImage)
 	if err != nil {
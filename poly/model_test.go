@@ -2,7 +2,12 @@ package poly
 
 import (
 	"encoding/base64"
+	"encoding/gob"
 	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -67,6 +72,160 @@ ubeK6t3gnXdG4wwziiii/UTKMOg6dbzJLFE4dSCP3rEdeOM8805tDsGMvySgSsS6rM6gk9eAcUUVftZt
 0HwyBXGeRjmrcUhMg2ghezd//rUUVcTKW5s2jZtY/QDaOKKKK8ip8bPRj8KP/9k=
 `
 
+func decodeTestImage(t testing.TB) image.Image {
+	reader := base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		t.Fatalf("unable to decode: %v", err)
+	}
+	return img
+}
+
+func TestOptimizeIncrementalScore(t *testing.T) {
+	img := decodeTestImage(t)
+	for _, kind := range []MetricKind{MSEMetric, BlockMSSSIMMetric} {
+		t.Run(string(kind), func(t *testing.T) {
+			model := NewModel(img, 25, 1, Color{255, 255, 255, 255})
+			if err := model.SetMetric(kind); err != nil {
+				t.Fatal(err)
+			}
+			checkIncrementalScore(t, model, model.Optimize(200, 4, 0))
+		})
+	}
+}
+
+// checkIncrementalScore fails the test when score, updated incrementally
+// during a run, differs from the score of a full render of the polygons.
+func checkIncrementalScore(t *testing.T, model *Model, score float64) {
+	t.Helper()
+	if want := model.score(model.Polygons).score; math.Abs(score-want) > 1e-9*math.Abs(want) {
+		t.Errorf("incremental score %v, full render score %v", score, want)
+	}
+}
+
+func TestOptimizePyramid(t *testing.T) {
+	img := decodeTestImage(t)
+	model := NewModel(img, 25, 1, Color{255, 255, 255, 255})
+	score := model.OptimizePyramid([]int{50, 50, 50}, 2, 0)
+
+	if model.Scale != 1 || model.Width != img.Bounds().Dx() || model.Height != img.Bounds().Dy() {
+		t.Errorf("model ended at scale %v with size %vx%v", model.Scale, model.Width, model.Height)
+	}
+	if want := model.score(model.Polygons).score; score != want {
+		t.Errorf("pyramid score %v, full render score %v", score, want)
+	}
+}
+
+func TestPyramidLevels(t *testing.T) {
+	for _, test := range []struct{ w, h, want int }{
+		{8, 8, 1},
+		{15, 100, 1},
+		{16, 16, 2},
+		{60, 40, 3},
+		{150, 103, 4},
+	} {
+		if got := PyramidLevels(test.w, test.h); got != test.want {
+			t.Errorf("%vx%v: %v levels, want %v", test.w, test.h, got, test.want)
+		}
+	}
+
+	// the extra coarse levels are merged instead of collapsing the
+	// polygons onto a single pixel
+	model := NewModel(decodeTestImage(t), 25, 1, Color{255, 255, 255, 255})
+	model.OptimizePyramid([]int{10, 10, 10, 10, 10, 10, 10}, 2, 0)
+	for _, polygon := range model.Polygons {
+		if polygon.bounds().Dx() <= 1 && polygon.bounds().Dy() <= 1 {
+			t.Fatalf("polygon %v collapsed", polygon)
+		}
+	}
+}
+
+func TestRenderAtOtherSize(t *testing.T) {
+	white, red := Color{255, 255, 255, 255}, Color{255, 0, 0, 255}
+	model := NewModel(uniformImage(20, 10, white), 1, 1, white)
+	// covers the centers of the pixels 2 to 5 and 1 to 3
+	model.Polygons = Polygons{{Color: red, Vertices: []Point{{1.5, 0.5}, {5.5, 0.5}, {5.5, 3.5}, {1.5, 3.5}}}}
+	model.OriginalWidth, model.OriginalHeight = 60, 20
+	model.Rescore()
+
+	width, height := model.OutputSize()
+	if width != 60 || height != 20 {
+		t.Fatalf("output size %vx%v, want 60x20", width, height)
+	}
+	rgba := model.RenderAt(width, height)
+	if rgba.Rect != image.Rect(0, 0, 60, 20) {
+		t.Fatalf("rendered %v, want 60x20", rgba.Rect)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			want := white
+			if x >= 6 && x < 18 && y >= 2 && y < 8 {
+				want = red
+			}
+			if got := rgba.RGBAAt(x, y); got != (color.RGBA{want.R, want.G, want.B, 255}) {
+				t.Fatalf("pixel %v,%v is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	svg := model.SVG()
+	for _, want := range []string{`width="60" height="20"`, `scale(3.000000 2.000000)`, `points="1.5,0.5 5.5,0.5 5.5,3.5 1.5,3.5 "`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG without %v:\n%v", want, svg)
+		}
+	}
+
+	model.OriginalWidth, model.OriginalHeight = 0, 0
+	model.Scale = 0.5
+	if width, height := model.OutputSize(); width != 40 || height != 20 {
+		t.Errorf("output size at scale 0.5 %vx%v, want 40x20", width, height)
+	}
+}
+
+func TestReadGobIntegerVertices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.gob")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyModel{
+		Width:  16,
+		Height: 8,
+		Scale:  1,
+		Polygons: []legacyPolygon{
+			{Color: Color{1, 2, 3, 4}, Vertices: []legacyPoint{{0, 0}, {15, 3}, {4, 7}}},
+		},
+	}
+	if err := gob.NewEncoder(file).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	var model Model
+	if err := ReadGob(path, &model); err != nil {
+		t.Fatal(err)
+	}
+	if model.Width != 16 || model.Height != 8 || len(model.Polygons) != 1 {
+		t.Fatalf("decoded %vx%v with %v polygons", model.Width, model.Height, len(model.Polygons))
+	}
+	if got := model.Polygons[0].Vertices[1]; got != (Point{15, 3}) {
+		t.Errorf("vertex decoded as %v, want {15 3}", got)
+	}
+}
+
+func TestReadGobUnknownMetric(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gob")
+	model := NewModel(decodeTestImage(t), 5, 1, Color{255, 255, 255, 255})
+	model.ReportMetric = "unknown"
+	if err := model.GOB(path); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Model
+	if err := ReadGob(path, &decoded); err == nil {
+		t.Error("unknown metric accepted")
+	}
+}
+
 func BenchmarkModel(b *testing.B) {
 	reader := base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))
 	img, _, err := image.Decode(reader)
@@ -83,6 +242,6 @@ func BenchmarkModel(b *testing.B) {
 	randomSeed := int64(349283)
 	model := NewModel(img, 25, randomSeed, whiteColor)
 	for n := 0; n < b.N; n++ {
-		_ = model.Optimize(5, 1)
+		_ = model.Optimize(5, 1, 1000)
 	}
 }
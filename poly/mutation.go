@@ -0,0 +1,400 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+type ColorMutationKind string
+
+const (
+	// RandomColorMutation replaces the color with a random one
+	RandomColorMutation ColorMutationKind = "random"
+	// UniformColorMutation moves a single channel by up to 50
+	UniformColorMutation ColorMutationKind = "uniform"
+	// GaussianColorMutation adds gaussian noise to every channel, with a
+	// standard deviation that adapts to the success of the run
+	GaussianColorMutation ColorMutationKind = "gaussian"
+)
+
+// Mutations configures the operators used to mutate a polygon. The weights
+// are relative to each other and an operator with a zero weight is never
+// picked.
+type Mutations struct {
+	// Vertex moves a vertex
+	Vertex float64
+	// Color changes the color of the polygon as set by ColorMutation
+	Color float64
+	// ColorMutation is how colors are mutated, RandomColorMutation when empty
+	ColorMutation ColorMutationKind
+	// ColorSigma is the standard deviation of the gaussian color mutation
+	ColorSigma float64
+	// Insert adds a vertex at the midpoint of an edge
+	Insert float64
+	// Delete removes a vertex
+	Delete float64
+	// Up and Down move a polygon one step in the drawing order, Swap
+	// exchanges it with another polygon and Front and Back move it on top
+	// or below every other polygon
+	Up, Down, Swap, Front, Back float64
+	// Translate, Scale and Rotate move the whole polygon, scaling and
+	// rotating it about its centroid
+	Translate, Scale, Rotate float64
+	// TranslateStrength is the largest displacement in pixels, ScaleStrength
+	// the largest relative change of size and RotateStrength the largest
+	// angle in radians of the whole polygon mutations
+	TranslateStrength, ScaleStrength, RotateStrength float64
+	// MinStrength is the fraction the strengths and ColorSigma can shrink
+	// to as the run converges
+	MinStrength float64
+	// ShapeOnly only searches the shapes and drawing order of the polygons,
+	// the color of a mutated polygon is set to the one that best fits the
+	// target and the color mutations are disabled. Alpha is kept.
+	ShapeOnly bool
+	// MinOrder and MaxOrder bound the number of vertices of new polygons and
+	// of the ones changed by insertions and deletions
+	MinOrder, MaxOrder int
+}
+
+// DefaultMutations moves vertices and changes colors with the same
+// probability and never changes the number of vertices.
+func DefaultMutations() *Mutations {
+	return &Mutations{
+		Vertex:            1,
+		Color:             1,
+		ColorMutation:     RandomColorMutation,
+		ColorSigma:        20,
+		MinOrder:          3,
+		MaxOrder:          5,
+		TranslateStrength: 10,
+		ScaleStrength:     0.2,
+		RotateStrength:    0.3,
+		MinStrength:       0.05,
+	}
+}
+
+// Validate checks the weights and order bounds.
+func (mt *Mutations) Validate() error {
+	weights := []float64{
+		mt.Vertex, mt.Color, mt.Insert, mt.Delete,
+		mt.Up, mt.Down, mt.Swap, mt.Front, mt.Back,
+		mt.Translate, mt.Scale, mt.Rotate,
+	}
+	var total float64
+	for _, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("mutation weights should be >= 0")
+		}
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one mutation weight should be > 0")
+	}
+	if mt.MinOrder < 3 || mt.MaxOrder < mt.MinOrder {
+		return fmt.Errorf("invalid polygon order bounds %v-%v", mt.MinOrder, mt.MaxOrder)
+	}
+	if mt.TranslateStrength < 0 || mt.ScaleStrength < 0 || mt.RotateStrength < 0 {
+		return fmt.Errorf("mutation strengths should be >= 0")
+	}
+	if mt.ScaleStrength >= 1 {
+		return fmt.Errorf("scale strength should be < 1")
+	}
+	switch mt.ColorMutation {
+	case "", RandomColorMutation, UniformColorMutation, GaussianColorMutation:
+	default:
+		return fmt.Errorf("unknown color mutation %q", mt.ColorMutation)
+	}
+	if mt.ColorSigma < 0 {
+		return fmt.Errorf("color sigma should be >= 0")
+	}
+	if mt.MinStrength <= 0 || mt.MinStrength > 1 {
+		return fmt.Errorf("minimum strength should be in (0, 1]")
+	}
+	return nil
+}
+
+// SetWeights parses a comma separated list of name=weight pairs, such as
+// "vertex=1,insert=0.2", and sets the weights of the named operators.
+func (mt *Mutations) SetWeights(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return fmt.Errorf("invalid mutation weight %q", pair)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid mutation weight %q", pair)
+		}
+		switch name {
+		case "vertex":
+			mt.Vertex = weight
+		case "color":
+			mt.Color = weight
+		case "insert":
+			mt.Insert = weight
+		case "delete":
+			mt.Delete = weight
+		case "up":
+			mt.Up = weight
+		case "down":
+			mt.Down = weight
+		case "swap":
+			mt.Swap = weight
+		case "front":
+			mt.Front = weight
+		case "back":
+			mt.Back = weight
+		case "translate":
+			mt.Translate = weight
+		case "scale":
+			mt.Scale = weight
+		case "rotate":
+			mt.Rotate = weight
+		default:
+			return fmt.Errorf("unknown mutation %q", name)
+		}
+	}
+	return mt.Validate()
+}
+
+// mutator holds what the mutation operators need to know about the model.
+type mutator struct {
+	*Mutations
+	width, height int
+	sampler       *sampler
+	alpha         *AlphaRange
+	// strength scales the whole polygon mutations and the gaussian color
+	// mutation
+	strength float64
+	// fitColor returns the best color of a polygon in shape only mode
+	fitColor func(polygons Polygons, i int) Color
+}
+
+// mutations returns the mutation settings of the model, the default ones
+// when Mutations is not set.
+func (m *Model) mutations() *Mutations {
+	if m.Mutations == nil {
+		return DefaultMutations()
+	}
+	return m.Mutations
+}
+
+func (m *Model) mutator() mutator {
+	mutations := m.mutations()
+	mt := mutator{
+		Mutations: mutations,
+		width:     m.Width,
+		height:    m.Height,
+		sampler:   m.sampler,
+		alpha:     m.Alpha,
+		strength:  m.strength.value(mutations.MinStrength),
+	}
+	if mutations.ShapeOnly {
+		mt.fitColor = m.optimalColor
+	}
+	return mt
+}
+
+// newRandomPolygon returns a polygon with a number of vertices inside the
+// order bounds, placed by the sampler, and an alpha inside the alpha range.
+func (mt mutator) newRandomPolygon() Polygon {
+	return Polygon{
+		Color:    mt.alpha.newColor(),
+		Vertices: newRandomVertices(mt.order(), mt.width, mt.height, mt.sampler),
+	}
+}
+
+// order returns a random number of vertices for a new polygon, inside the
+// order bounds.
+func (mt mutator) order() int {
+	return mt.MinOrder + rand.Intn(mt.MaxOrder-mt.MinOrder+1)
+}
+
+// strengthWindow is the number of iterations between two adaptations of the
+// mutation strength.
+const strengthWindow = 50
+
+// strength adapts the whole polygon mutations and the standard deviation of
+// the gaussian color mutation with the 1/5th success rule.
+// It shrinks when less than a fifth of the recent iterations improved the
+// model, which happens more and more as the run converges, and grows back
+// otherwise.
+type strength struct {
+	// factor scales the configured strengths, 0 until the first adaptation
+	factor           float64
+	improved, trials int
+}
+
+func (s *strength) value(min float64) float64 {
+	if s.factor == 0 {
+		return 1
+	}
+	return math.Max(s.factor, min)
+}
+
+func (s *strength) update(improved bool, min float64) {
+	s.trials++
+	if improved {
+		s.improved++
+	}
+	if s.trials < strengthWindow {
+		return
+	}
+	factor := s.value(min)
+	if rate := float64(s.improved) / float64(s.trials); rate < 0.2 {
+		factor *= 0.85
+	} else if rate > 0.2 {
+		factor /= 0.85
+	}
+	s.factor = math.Min(1, math.Max(min, factor))
+	s.improved, s.trials = 0, 0
+}
+
+type mutationKind int
+
+const (
+	vertexMutation mutationKind = iota
+	colorMutation
+	insertMutation
+	deleteMutation
+	upMutation
+	downMutation
+	swapMutation
+	frontMutation
+	backMutation
+	translateMutation
+	scaleMutation
+	rotateMutation
+)
+
+// pick chooses a mutation operator for the polygon according to the weights,
+// skipping insertions and deletions that would break the order bounds. The
+// drawing order operators are only picked when there are several polygons.
+func (mt mutator) pick(polygon *Polygon, polygons int) mutationKind {
+	weights := [...]float64{
+		vertexMutation: mt.Vertex,
+		colorMutation:  mt.Color,
+		insertMutation: mt.Insert,
+		deleteMutation: mt.Delete,
+		upMutation:     mt.Up,
+		downMutation:   mt.Down,
+		swapMutation:   mt.Swap,
+		frontMutation:  mt.Front,
+		backMutation:   mt.Back,
+
+		translateMutation: mt.Translate,
+		scaleMutation:     mt.Scale,
+		rotateMutation:    mt.Rotate,
+	}
+	if mt.ShapeOnly {
+		weights[colorMutation] = 0
+	}
+	if len(polygon.Vertices) >= mt.MaxOrder {
+		weights[insertMutation] = 0
+	}
+	if len(polygon.Vertices) <= mt.MinOrder {
+		weights[deleteMutation] = 0
+	}
+	if polygons < 2 {
+		for kind := upMutation; kind <= backMutation; kind++ {
+			weights[kind] = 0
+		}
+	}
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return vertexMutation
+	}
+	r := rand.Float64() * total
+	for kind, w := range weights {
+		r -= w
+		if r < 0 {
+			return mutationKind(kind)
+		}
+	}
+	return vertexMutation
+}
+
+// mutate applies a random mutation to the polygons in place and returns the
+// rectangle containing every pixel it can change. A mutated polygon is cloned
+// first so the slice can share its polygons with the model. Moving a polygon
+// in the drawing order only changes the pixels it covers, and swapping two
+// polygons the pixels covered by either of them. In shape only mode the
+// mutated, moved or swapped polygons get the color that best fits their new
+// place.
+func (mt mutator) mutate(polygons Polygons, ratio float64) image.Rectangle {
+	if len(polygons) == 0 {
+		return image.Rectangle{}
+	}
+	i := rand.Intn(len(polygons))
+	kind := mt.pick(&polygons[i], len(polygons))
+	switch kind {
+	case upMutation, downMutation, frontMutation, backMutation:
+		j := i
+		switch kind {
+		case upMutation:
+			j = i + 1
+		case downMutation:
+			j = i - 1
+		case frontMutation:
+			j = len(polygons) - 1
+		case backMutation:
+			j = 0
+		}
+		if j < 0 || j >= len(polygons) {
+			return image.Rectangle{}
+		}
+		dirty := polygons[i].bounds()
+		movePolygon(polygons, i, j)
+		if mt.fitColor != nil {
+			polygons[j].Color = mt.fitColor(polygons, j)
+		}
+		return dirty
+	case swapMutation:
+		j := rand.Intn(len(polygons) - 1)
+		if j >= i {
+			j++
+		}
+		polygons[i], polygons[j] = polygons[j], polygons[i]
+		if mt.fitColor != nil {
+			// the color of the upper polygon depends on the lower one
+			if j < i {
+				i, j = j, i
+			}
+			polygons[i].Color = mt.fitColor(polygons, i)
+			polygons[j].Color = mt.fitColor(polygons, j)
+		}
+		return polygons[i].bounds().Union(polygons[j].bounds())
+	}
+	return mt.mutatePolygon(polygons, i, kind, ratio)
+}
+
+// mutatePolygon applies a mutation that only changes the polygon at index i
+// and returns the rectangle containing every pixel it can change.
+func (mt mutator) mutatePolygon(polygons Polygons, i int, kind mutationKind, ratio float64) image.Rectangle {
+	old := polygons[i]
+	polygons[i] = old.clone()
+	polygons[i].apply(kind, ratio, mt)
+	if mt.fitColor != nil {
+		polygons[i].Color = mt.fitColor(polygons, i)
+	}
+	return old.bounds().Union(polygons[i].bounds())
+}
+
+// movePolygon moves the polygon at index from to index to, shifting the
+// polygons in between.
+func movePolygon(polygons Polygons, from, to int) {
+	p := polygons[from]
+	if from < to {
+		copy(polygons[from:to], polygons[from+1:to+1])
+	} else {
+		copy(polygons[to+1:from+1], polygons[to:from])
+	}
+	polygons[to] = p
+}
@@ -0,0 +1,216 @@
+package poly
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestInsertVertexKeepsShape(t *testing.T) {
+	rand.Seed(3)
+	polygon := newRandomPolygon(4, 40, 30, nil)
+	polygon.Color = Color{A: 255}
+	want := blankCanvas(40, 30)
+	rasterizePolygon(polygon, NonZero, want)
+
+	polygon.insertVertex()
+	if len(polygon.Vertices) != 5 {
+		t.Fatalf("polygon has %v vertices after an insertion, want 5", len(polygon.Vertices))
+	}
+	got := blankCanvas(40, 30)
+	rasterizePolygon(polygon, NonZero, got)
+	if !bytes.Equal(want.Pix, got.Pix) {
+		t.Errorf("inserting a vertex at an edge midpoint changed the shape")
+	}
+}
+
+func TestMutationsRespectOrderBounds(t *testing.T) {
+	rand.Seed(5)
+	mt := mutator{
+		Mutations: &Mutations{Insert: 1, Delete: 1, MinOrder: 3, MaxOrder: 6},
+		width:     32,
+		height:    32,
+	}
+	polygon := newRandomPolygon(4, 32, 32, nil)
+	seen := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		polygon.apply(mt.pick(&polygon, 1), rand.Float64(), mt)
+		n := len(polygon.Vertices)
+		if n < 3 || n > 6 {
+			t.Fatalf("polygon reached %v vertices, want 3 to 6", n)
+		}
+		seen[n] = true
+	}
+	if !seen[3] || !seen[6] {
+		t.Errorf("orders reached %v, want every order from 3 to 6", seen)
+	}
+}
+
+func TestNewPolygonsRespectOrderBounds(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 20, 1, Color{255, 255, 255, 255})
+	model.Mutations = DefaultMutations()
+	model.Mutations.MinOrder, model.Mutations.MaxOrder = 6, 7
+	if err := model.Initialize(RandomInit); err != nil {
+		t.Fatal(err)
+	}
+	model.grow()
+	for _, polygon := range model.Polygons {
+		if n := len(polygon.Vertices); n < 6 || n > 7 {
+			t.Fatalf("new polygon with %v vertices, want 6 to 7", n)
+		}
+	}
+}
+
+func TestSetWeights(t *testing.T) {
+	mt := DefaultMutations()
+	if err := mt.SetWeights("color=0, insert=0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if mt.Vertex != 1 || mt.Color != 0 || mt.Insert != 0.5 {
+		t.Errorf("weights %+v", mt)
+	}
+	if err := mt.SetWeights("twist=1"); err == nil {
+		t.Errorf("unknown mutations should fail")
+	}
+	if err := DefaultMutations().SetWeights("vertex=0,color=0"); err == nil {
+		t.Errorf("all zero weights should fail")
+	}
+}
+
+func TestMovePolygon(t *testing.T) {
+	polygons := make(Polygons, 5)
+	for i := range polygons {
+		polygons[i].Color.R = uint8(i)
+	}
+	order := func() []uint8 {
+		var o []uint8
+		for _, p := range polygons {
+			o = append(o, p.Color.R)
+		}
+		return o
+	}
+	movePolygon(polygons, 1, 3)
+	if got := order(); !bytes.Equal(got, []uint8{0, 2, 3, 1, 4}) {
+		t.Errorf("moving up gave order %v", got)
+	}
+	movePolygon(polygons, 4, 0)
+	if got := order(); !bytes.Equal(got, []uint8{4, 0, 2, 3, 1}) {
+		t.Errorf("moving down gave order %v", got)
+	}
+}
+
+func TestSwapRefitsColors(t *testing.T) {
+	mt := mutator{
+		Mutations: &Mutations{Swap: 1, MinOrder: 3, MaxOrder: 10},
+		width:     20,
+		height:    20,
+		fitColor: func(polygons Polygons, i int) Color {
+			return Color{R: uint8(i), A: 255}
+		},
+	}
+	polygons := make(Polygons, 5)
+	for i := range polygons {
+		polygons[i] = newRandomPolygon(3, 20, 20, nil)
+		polygons[i].Color = Color{}
+	}
+	mt.mutate(polygons, 0.5)
+	refit := 0
+	for i, p := range polygons {
+		if p.Color.A == 255 {
+			refit++
+			if p.Color.R != uint8(i) {
+				t.Errorf("polygon %v got the color fitted for %v", i, p.Color.R)
+			}
+		}
+	}
+	if refit != 2 {
+		t.Errorf("%v polygons refitted after a swap, want 2", refit)
+	}
+}
+
+func TestOptimizeReorderScore(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 25, 1, Color{255, 255, 255, 255})
+	model.Mutations = &Mutations{Vertex: 1, Color: 1, Up: 1, Down: 1, Swap: 1, Front: 1, Back: 1, MinOrder: 3, MaxOrder: 10}
+	checkIncrementalScore(t, model, model.Optimize(300, 2, 0))
+}
+
+func TestAffineMutationsStayInsideCanvas(t *testing.T) {
+	rand.Seed(7)
+	mt := mutator{
+		Mutations: &Mutations{Translate: 1, Scale: 1, Rotate: 1, MinOrder: 3, MaxOrder: 10,
+			TranslateStrength: 30, ScaleStrength: 0.5, RotateStrength: 1},
+		width:    40,
+		height:   20,
+		strength: 1,
+	}
+	polygon := newRandomPolygon(5, 40, 20, nil)
+	for i := 0; i < 1000; i++ {
+		polygon.apply(mt.pick(&polygon, 1), rand.Float64(), mt)
+		for _, v := range polygon.Vertices {
+			if v.X < 0 || v.X > 39 || v.Y < 0 || v.Y > 19 {
+				t.Fatalf("vertex %v outside the canvas", v)
+			}
+		}
+	}
+}
+
+func TestCenterIsCentroid(t *testing.T) {
+	// the extra vertex on the left side moves the vertex mean but not the
+	// centroid of the square
+	polygon := Polygon{Vertices: []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 2}}}
+	if got := polygon.center(); math.Abs(got.X-2) > 1e-9 || math.Abs(got.Y-2) > 1e-9 {
+		t.Errorf("center %v, want {2 2}", got)
+	}
+	flat := Polygon{Vertices: []Point{{0, 0}, {2, 2}, {4, 4}}}
+	if got := flat.center(); got != (Point{2, 2}) {
+		t.Errorf("center of a flat polygon %v, want {2 2}", got)
+	}
+}
+
+func TestTranslateKeepsShape(t *testing.T) {
+	rand.Seed(9)
+	polygon := Polygon{Vertices: []Point{{10, 10}, {20, 12}, {14, 18}}}
+	before := polygon.clone()
+	polygon.translate(5, 40, 40)
+	dx, dy := polygon.Vertices[0].X-before.Vertices[0].X, polygon.Vertices[0].Y-before.Vertices[0].Y
+	for i, v := range polygon.Vertices {
+		if math.Abs(v.X-before.Vertices[i].X-dx) > 1e-9 || math.Abs(v.Y-before.Vertices[i].Y-dy) > 1e-9 {
+			t.Fatalf("translation changed the shape: %v -> %v", before.Vertices, polygon.Vertices)
+		}
+	}
+}
+
+func TestStrengthShrinksWithoutImprovements(t *testing.T) {
+	var s strength
+	for i := 0; i < 100*strengthWindow; i++ {
+		s.update(false, 0.05)
+	}
+	if v := s.value(0.05); v != 0.05 {
+		t.Errorf("strength %v without improvements, want the minimum 0.05", v)
+	}
+	for i := 0; i < 100*strengthWindow; i++ {
+		s.update(i%2 == 0, 0.05)
+	}
+	if v := s.value(0.05); v != 1 {
+		t.Errorf("strength %v with frequent improvements, want 1", v)
+	}
+}
+
+func TestGaussianColorMutation(t *testing.T) {
+	rand.Seed(11)
+	var sum, sumSquares float64
+	n := 2000
+	for i := 0; i < n; i++ {
+		polygon := Polygon{Color: Color{128, 128, 128, 128}}
+		polygon.mutateColorGaussian(10)
+		d := float64(polygon.Color.G) - 128
+		sum += d
+		sumSquares += d * d
+	}
+	mean := sum / float64(n)
+	sigma := math.Sqrt(sumSquares/float64(n) - mean*mean)
+	if math.Abs(mean) > 1 || math.Abs(sigma-10) > 1 {
+		t.Errorf("channel changed with mean %v and sigma %v, want 0 and 10", mean, sigma)
+	}
+}
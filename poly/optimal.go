@@ -0,0 +1,91 @@
+package poly
+
+import (
+	"image"
+	"math"
+)
+
+// coverage returns how much of every pixel of r the polygon covers, from 0
+// to 255, in the red channel of the image.
+func (m *Model) coverage(polygon Polygon, r image.Rectangle) *image.RGBA {
+	// an opaque white polygon on a black canvas
+	coverage := image.NewRGBA(r)
+	rasterize(Polygon{Color: Color{255, 255, 255, 255}, Vertices: polygon.Vertices}, m.FillRule, m.AntiAlias, coverage)
+	return coverage
+}
+
+// meanColor returns the average target color under the polygon, weighted by
+// the coverage of every pixel, keeping the alpha of the polygon.
+func (m *Model) meanColor(polygon Polygon) Color {
+	r := polygon.bounds().Intersect(m.TargetImage.Rect)
+	if r.Empty() {
+		return polygon.Color
+	}
+	coverage := m.coverage(polygon, r)
+	var sum [3]float64
+	var total float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			covered := float64(coverage.Pix[coverage.PixOffset(x, y)])
+			t := m.TargetImage.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				sum[c] += covered * float64(m.TargetImage.Pix[t+c])
+			}
+			total += covered
+		}
+	}
+	if total == 0 {
+		return polygon.Color
+	}
+	color := polygon.Color
+	channels := [3]*uint8{&color.R, &color.G, &color.B}
+	for c, channel := range channels {
+		*channel = uint8(math.Round(sum[c] / total))
+	}
+	return color
+}
+
+// optimalColor returns the color of the polygon at index i that minimizes
+// the weighted squared error of the pixels it covers, keeping its alpha.
+// Every covered pixel ends up as a*c + (1-a)*u, where a is the alpha scaled
+// by the coverage of the pixel and u the rendering of the polygons below, so
+// the best c is a closed form least squares. The polygons above are ignored.
+func (m *Model) optimalColor(polygons Polygons, i int) Color {
+	polygon := polygons[i]
+	r := polygon.bounds().Intersect(m.TargetImage.Rect)
+	if r.Empty() || len(polygon.Vertices) < 3 {
+		return polygon.Color
+	}
+	under := renderRegion(polygons[:i], m.BackgroundColor, m.FillRule, m.AntiAlias, r)
+	coverage := m.coverage(polygon, r)
+
+	alpha := float64(int(polygon.Color.A)+1) / 256
+	w := weights{m.Mask}
+	var num [3]float64
+	var den float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			covered := coverage.Pix[coverage.PixOffset(x, y)]
+			if covered == 0 {
+				continue
+			}
+			a := alpha * float64(covered) / 255
+			weight := float64(w.at(x, y))
+			t := m.TargetImage.PixOffset(x, y)
+			u := under.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				num[c] += weight * a * (float64(m.TargetImage.Pix[t+c]) - (1-a)*float64(under.Pix[u+c]))
+			}
+			den += weight * a * a
+		}
+	}
+	if den == 0 {
+		return polygon.Color
+	}
+	color := polygon.Color
+	channels := [3]*uint8{&color.R, &color.G, &color.B}
+	for c, channel := range channels {
+		*channel = uint8(clampFloat(math.Round(num[c]/den), 0, 255))
+	}
+	return color
+}
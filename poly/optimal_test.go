@@ -0,0 +1,34 @@
+package poly
+
+import (
+	"testing"
+)
+
+func TestOptimalColor(t *testing.T) {
+	model := NewModel(uniformImage(20, 20, Color{100, 150, 200, 255}), 1, 1, Color{50, 50, 50, 255})
+	model.Polygons[0] = Polygon{
+		Color:    Color{A: 127},
+		Vertices: []Point{{2, 2}, {17, 3}, {9, 16}},
+	}
+	got := model.optimalColor(model.Polygons, 0)
+	// half transparent over the background, 2*target - background
+	want := Color{150, 250, 255, 127}
+	if got != want {
+		t.Errorf("optimal color %v, want %v", got, want)
+	}
+}
+
+func TestOptimizeShapeOnlyScore(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 25, 1, Color{255, 255, 255, 255})
+	mutations := DefaultMutations()
+	mutations.ShapeOnly = true
+	mutations.Up, mutations.Down = 1, 1
+	model.Mutations = mutations
+	initial := model.Score
+	score := model.Optimize(200, 2, 0)
+
+	if score >= initial {
+		t.Errorf("shape only search did not improve the score %v", initial)
+	}
+	checkIncrementalScore(t, model, score)
+}
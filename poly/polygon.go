@@ -1,24 +1,20 @@
 package poly
 
 import (
+	"math"
 	"math/rand"
 )
 
 type Polygon struct {
 	Color Color
 	// Vertices represents the list of coordinates for the vertices of the polygon
-	Vertices  []Point
-	Points    []Point
-	HasPoints bool
+	Vertices []Point
 }
 
 func (p Polygon) clone() Polygon {
 	var polygon Polygon
 	polygon.Vertices = make([]Point, len(p.Vertices))
-	polygon.Points = make([]Point, len(p.Points))
 	copy(polygon.Vertices, p.Vertices)
-	copy(polygon.Points, p.Points)
-	polygon.HasPoints = p.HasPoints
 	polygon.Color = p.Color
 	return polygon
 }
@@ -34,31 +30,46 @@ func (ps Polygons) clone() Polygons {
 	return polygons
 }
 
-func newRandomPolygon(order, maxX, maxY int) Polygon {
-	points := newRandomVertices(order, maxX, maxY)
+func newRandomPolygon(order, maxX, maxY int, s *sampler) Polygon {
+	points := newRandomVertices(order, maxX, maxY, s)
 	polygon := Polygon{}
 	polygon.Vertices = points
 	polygon.Color = newRandomColor()
-	polygon.HasPoints = false
 	return polygon
 }
 
-func (polygon *Polygon) mutate(ratio float64, w, h int) {
-	randomFloat := rand.Float64()
-	if randomFloat > 0.5 {
-		polygon.mutateVertex(ratio, w, h)
-	} else {
-		polygon.Color = NewRandomColor()
-		//polygon.mutateColor()
+// apply runs a mutation operator that only changes the polygon itself.
+func (polygon *Polygon) apply(kind mutationKind, ratio float64, mt mutator) {
+	switch kind {
+	case vertexMutation:
+		polygon.mutateVertex(ratio, mt.width, mt.height, mt.sampler)
+	case colorMutation:
+		switch mt.ColorMutation {
+		case UniformColorMutation:
+			polygon.mutateColor()
+		case GaussianColorMutation:
+			polygon.mutateColorGaussian(mt.ColorSigma * mt.strength)
+		default:
+			polygon.Color = mt.alpha.randomColor()
+		}
+		polygon.Color = mt.alpha.clamp(polygon.Color)
+	case insertMutation:
+		polygon.insertVertex()
+	case deleteMutation:
+		polygon.deleteVertex()
+	case translateMutation:
+		polygon.translate(mt.TranslateStrength*mt.strength, mt.width, mt.height)
+	case scaleMutation:
+		polygon.scale(mt.ScaleStrength*mt.strength, mt.width, mt.height)
+	case rotateMutation:
+		polygon.rotate(mt.RotateStrength*mt.strength, mt.width, mt.height)
 	}
-
-	return
 }
 
 func (polygon *Polygon) mutateColor() {
 	amplitude := 50
 	channel := rand.Intn(4)
-	// displacement is an integer in the interval [-50, 100)
+	// displacement is an integer in the interval [-50, 50)
 	displacement := rand.Intn(2*amplitude) - amplitude
 	colorList := [4]uint8{
 		polygon.Color.R,
@@ -76,27 +87,131 @@ func (polygon *Polygon) mutateColor() {
 		}
 }
 
-func (polygon *Polygon) mutateVertex(ratio float64, width, height int) {
-	polygon.HasPoints = false
+// mutateColorGaussian adds gaussian noise with standard deviation sigma to
+// every channel.
+func (polygon *Polygon) mutateColorGaussian(sigma float64) {
+	channels := [4]*uint8{&polygon.Color.R, &polygon.Color.G, &polygon.Color.B, &polygon.Color.A}
+	for _, c := range channels {
+		*c = uint8(clampFloat(math.Round(float64(*c)+rand.NormFloat64()*sigma), 0, 255))
+	}
+}
+
+func (polygon *Polygon) mutateVertex(ratio float64, width, height int, s *sampler) {
 	randomVertexIndex := rand.Intn(len(polygon.Vertices))
 
-	var p [2]int
+	var p [2]float64
 	if ratio < 0.07 {
-		p = [2]int{
+		p = [2]float64{
 			polygon.Vertices[randomVertexIndex].X,
 			polygon.Vertices[randomVertexIndex].Y,
 		}
-		amplitude := 10
-		displacement := rand.Intn(2*amplitude+1) - amplitude
+		amplitude := 10.0
+		// displacement is a real number in the interval [-10, 10)
+		displacement := (2*rand.Float64() - 1) * amplitude
 		direction := rand.Intn(2)
-		maxValue := [2]int{width, height}
-		p[direction] = int(clamp(p[direction], displacement, 0, maxValue[direction]))
+		maxValue := [2]float64{float64(width - 1), float64(height - 1)}
+		p[direction] = clampFloat(p[direction]+displacement, 0, maxValue[direction])
 	} else {
-		p = [2]int{rand.Intn(width), rand.Intn(height)}
+		point := s.point(width, height)
+		p = [2]float64{point.X, point.Y}
 	}
 	polygon.Vertices[randomVertexIndex] = Point{p[0], p[1]}
 }
 
+// insertVertex splits a random edge adding a vertex at its midpoint. The
+// shape does not change until the new vertex is moved.
+func (polygon *Polygon) insertVertex() {
+	i := rand.Intn(len(polygon.Vertices))
+	a, b := polygon.Vertices[i], polygon.Vertices[(i+1)%len(polygon.Vertices)]
+	midpoint := Point{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+	vertices := make([]Point, 0, len(polygon.Vertices)+1)
+	vertices = append(vertices, polygon.Vertices[:i+1]...)
+	vertices = append(vertices, midpoint)
+	vertices = append(vertices, polygon.Vertices[i+1:]...)
+	polygon.Vertices = vertices
+}
+
+// deleteVertex removes a random vertex.
+func (polygon *Polygon) deleteVertex() {
+	i := rand.Intn(len(polygon.Vertices))
+	vertices := make([]Point, 0, len(polygon.Vertices)-1)
+	vertices = append(vertices, polygon.Vertices[:i]...)
+	vertices = append(vertices, polygon.Vertices[i+1:]...)
+	polygon.Vertices = vertices
+}
+
+// translate moves the polygon by up to amplitude pixels on each axis,
+// limiting the displacement so the polygon stays inside the canvas.
+func (polygon *Polygon) translate(amplitude float64, width, height int) {
+	minX, maxX, minY, maxY := minMaxPoints(polygon.Vertices)
+	dx := clampFloat((2*rand.Float64()-1)*amplitude, -minX, float64(width-1)-maxX)
+	dy := clampFloat((2*rand.Float64()-1)*amplitude, -minY, float64(height-1)-maxY)
+	for i := range polygon.Vertices {
+		polygon.Vertices[i].X += dx
+		polygon.Vertices[i].Y += dy
+	}
+}
+
+// scale resizes the polygon about its centroid by a factor in
+// [1-amplitude, 1+amplitude].
+func (polygon *Polygon) scale(amplitude float64, width, height int) {
+	factor := 1 + (2*rand.Float64()-1)*amplitude
+	center := polygon.center()
+	polygon.transform(func(p Point) Point {
+		return Point{
+			center.X + (p.X-center.X)*factor,
+			center.Y + (p.Y-center.Y)*factor,
+		}
+	}, width, height)
+}
+
+// rotate turns the polygon about its centroid by an angle in
+// [-amplitude, amplitude] radians.
+func (polygon *Polygon) rotate(amplitude float64, width, height int) {
+	sin, cos := math.Sincos((2*rand.Float64() - 1) * amplitude)
+	center := polygon.center()
+	polygon.transform(func(p Point) Point {
+		dx, dy := p.X-center.X, p.Y-center.Y
+		return Point{
+			center.X + dx*cos - dy*sin,
+			center.Y + dx*sin + dy*cos,
+		}
+	}, width, height)
+}
+
+// center returns the centroid of the polygon area, or the mean of the
+// vertices when the polygon has no area.
+func (polygon *Polygon) center() Point {
+	var mean, c Point
+	var area float64
+	n := len(polygon.Vertices)
+	for i, v := range polygon.Vertices {
+		w := polygon.Vertices[(i+1)%n]
+		cross := v.X*w.Y - w.X*v.Y
+		area += cross
+		c.X += (v.X + w.X) * cross
+		c.Y += (v.Y + w.Y) * cross
+		mean.X += v.X
+		mean.Y += v.Y
+	}
+	if math.Abs(area) < 1e-9 {
+		return Point{mean.X / float64(n), mean.Y / float64(n)}
+	}
+	// area is twice the signed area
+	return Point{c.X / (3 * area), c.Y / (3 * area)}
+}
+
+// transform applies f to every vertex and clamps the result to the canvas.
+func (polygon *Polygon) transform(f func(Point) Point, width, height int) {
+	for i, v := range polygon.Vertices {
+		p := f(v)
+		polygon.Vertices[i] = Point{
+			clampFloat(p.X, 0, float64(width-1)),
+			clampFloat(p.Y, 0, float64(height-1)),
+		}
+	}
+}
+
 func newRandomColor() Color {
 	var color [4]uint8
 	for i := 0; i < 3; i++ {
@@ -106,10 +221,10 @@ func newRandomColor() Color {
 	return Color{color[0], color[1], color[2], color[3]}
 }
 
-func newRandomVertices(order, maxX, maxY int) []Point {
+func newRandomVertices(order, maxX, maxY int, s *sampler) []Point {
 	points := make([]Point, order)
 	for i := 0; i < order; i++ {
-		points[i] = Point{rand.Intn(maxX), rand.Intn(maxY)}
+		points[i] = s.point(maxX, maxY)
 	}
 	return points
 }
@@ -0,0 +1,238 @@
+package poly
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+type SelectionKind string
+
+const (
+	TournamentSelection SelectionKind = "tournament"
+	RouletteSelection   SelectionKind = "roulette"
+)
+
+type CrossoverKind string
+
+const (
+	// UniformCrossover takes every polygon from either parent
+	UniformCrossover CrossoverKind = "uniform"
+	// OnePointCrossover takes the polygons before a random cut from one
+	// parent and the rest from the other
+	OnePointCrossover CrossoverKind = "one-point"
+	// PolygonCrossover recombines each pair of polygons vertex by vertex
+	PolygonCrossover CrossoverKind = "per-polygon"
+)
+
+// Population configures the genetic algorithm used by Optimize instead of
+// the single individual hill climber.
+type Population struct {
+	Size      int
+	Selection SelectionKind
+	// TournamentSize is the number of individuals competing in every
+	// tournament when using tournament selection
+	TournamentSize int
+	// Elitism is the number of best individuals copied unchanged to the
+	// next generation
+	Elitism   int
+	Crossover CrossoverKind
+	// CrossoverRate is the probability of a child being bred from two
+	// parents instead of being a copy of one
+	CrossoverRate float64
+}
+
+func NewPopulation(size int, selection SelectionKind, crossover CrossoverKind) (*Population, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("population size should be > 1")
+	}
+	switch selection {
+	case TournamentSelection, RouletteSelection:
+	default:
+		return nil, fmt.Errorf("unknown selection %q", selection)
+	}
+	switch crossover {
+	case UniformCrossover, OnePointCrossover, PolygonCrossover:
+	default:
+		return nil, fmt.Errorf("unknown crossover %q", crossover)
+	}
+	p := Population{
+		Size:           size,
+		Selection:      selection,
+		TournamentSize: 3,
+		Elitism:        1,
+		Crossover:      crossover,
+		CrossoverRate:  0.7,
+	}
+	return &p, nil
+}
+
+// Validate checks the settings that can be changed after NewPopulation.
+func (p *Population) Validate() error {
+	if p.Elitism < 0 || p.Elitism >= p.Size {
+		return fmt.Errorf("elitism should be in [0, %v)", p.Size)
+	}
+	if p.TournamentSize < 1 {
+		return fmt.Errorf("tournament size should be > 0")
+	}
+	if p.CrossoverRate < 0 || p.CrossoverRate > 1 {
+		return fmt.Errorf("crossover rate should be in [0, 1]")
+	}
+	return nil
+}
+
+// evolve runs the genetic algorithm for the given number of generations. The
+// first individual is the current model and the rest are random, the best
+// individual is written back to the model after every generation.
+func (m *Model) evolve(iterations, concurrency, logFrequency int) float64 {
+	config := m.Population
+	elitism := config.Elitism
+	if elitism > config.Size {
+		elitism = config.Size
+	}
+	if elitism < 0 {
+		elitism = 0
+	}
+	var successful int
+
+	mt := m.mutator()
+	population := make([]candidate, config.Size)
+	population[0] = candidate{polygons: m.Polygons, score: m.Score}
+	parallel(config.Size-1, concurrency, func(i int) {
+		var polygons Polygons
+		for j := 0; j < m.NumPolygons; j++ {
+			polygons = append(polygons, mt.newRandomPolygon())
+		}
+		population[i+1] = m.score(polygons)
+	})
+
+	for i := 1; i <= iterations; i++ {
+		sort.Slice(population, func(a, b int) bool {
+			return population[a].score < population[b].score
+		})
+		next := make([]candidate, config.Size)
+		elites := copy(next, population[:elitism])
+		parallel(config.Size-elites, concurrency, func(j int) {
+			a := m.selectParent(population)
+			child := a.polygons
+			if rand.Float64() < config.CrossoverRate {
+				b := m.selectParent(population)
+				child = crossover(config.Crossover, a.polygons, b.polygons)
+			} else {
+				child = child.clone()
+			}
+			mt.mutate(child, rand.Float64())
+			next[elites+j] = m.score(child)
+		})
+		population = next
+
+		best := population[0]
+		for _, c := range population[1:] {
+			if c.score < best.score {
+				best = c
+			}
+		}
+		improved := best.score < m.Score
+		if improved {
+			m.commit(best)
+			successful++
+		}
+		m.strength.update(improved, mt.MinStrength)
+		mt.strength = m.strength.value(mt.MinStrength)
+		m.Iteration++
+		if logFrequency > 0 && i%logFrequency == 0 {
+			m.logProgress(successful, nil)
+		}
+	}
+
+	fmt.Printf("successful generations: %v\n", successful)
+
+	return m.Score
+}
+
+// selectParent picks an individual of the population according to the
+// configured selection method.
+func (m *Model) selectParent(population []candidate) candidate {
+	config := m.Population
+	switch config.Selection {
+	case RouletteSelection:
+		// lower scores are better, so every individual gets a slice of the
+		// wheel proportional to how much better it is than the worst one,
+		// relative to the score range so it does not depend on the metric
+		best, worst := population[0], population[0]
+		for _, c := range population {
+			if c.score < best.score {
+				best = c
+			}
+			if c.score > worst.score {
+				worst = c
+			}
+		}
+		span := worst.score - best.score
+		if span == 0 {
+			return population[rand.Intn(len(population))]
+		}
+		var total float64
+		for _, c := range population {
+			total += (worst.score - c.score) / span
+		}
+		r := rand.Float64() * total
+		for _, c := range population {
+			r -= (worst.score - c.score) / span
+			if r < 0 {
+				return c
+			}
+		}
+		return best
+	default:
+		best := population[rand.Intn(len(population))]
+		for k := 1; k < config.TournamentSize; k++ {
+			c := population[rand.Intn(len(population))]
+			if c.score < best.score {
+				best = c
+			}
+		}
+		return best
+	}
+}
+
+// crossover breeds a new set of polygons from two parents. The parents are
+// not modified.
+func crossover(kind CrossoverKind, a, b Polygons) Polygons {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	child := a.clone()
+	switch kind {
+	case OnePointCrossover:
+		cut := rand.Intn(n + 1)
+		for i := cut; i < n; i++ {
+			child[i] = b[i].clone()
+		}
+	case PolygonCrossover:
+		for i := 0; i < n; i++ {
+			if len(a[i].Vertices) != len(b[i].Vertices) {
+				if rand.Intn(2) == 0 {
+					child[i] = b[i].clone()
+				}
+				continue
+			}
+			for v := range child[i].Vertices {
+				if rand.Intn(2) == 0 {
+					child[i].Vertices[v] = b[i].Vertices[v]
+				}
+			}
+			if rand.Intn(2) == 0 {
+				child[i].Color = b[i].Color
+			}
+		}
+	default:
+		for i := 0; i < n; i++ {
+			if rand.Intn(2) == 0 {
+				child[i] = b[i].clone()
+			}
+		}
+	}
+	return child
+}
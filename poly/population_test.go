@@ -0,0 +1,142 @@
+package poly
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPopulationValidate(t *testing.T) {
+	p, err := NewPopulation(10, TournamentSelection, UniformCrossover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("default population: %v", err)
+	}
+	for _, elitism := range []int{-1, 10} {
+		p.Elitism = elitism
+		if p.Validate() == nil {
+			t.Errorf("elitism %v accepted", elitism)
+		}
+	}
+
+	model := NewModel(decodeTestImage(t), 5, 1, Color{255, 255, 255, 255})
+	model.Population, _ = NewPopulation(10, TournamentSelection, UniformCrossover)
+	model.Annealing, _ = NewSchedule(LinearSchedule, 0.01, 0.001)
+	if model.Validate() == nil {
+		t.Error("annealing with a population accepted")
+	}
+}
+
+// parents returns two sets of n quadrilaterals, red ones and blue ones.
+func parents(n int) (Polygons, Polygons) {
+	var a, b Polygons
+	for i := 0; i < n; i++ {
+		x := float64(i)
+		a = append(a, Polygon{Color: Color{R: 255, A: 255}, Vertices: []Point{{x, 0}, {x, 1}, {x, 2}, {x, 3}}})
+		b = append(b, Polygon{Color: Color{B: 255, A: 255}, Vertices: []Point{{x, 10}, {x, 11}, {x, 12}, {x, 13}}})
+	}
+	return a, b
+}
+
+func TestCrossover(t *testing.T) {
+	rand.Seed(3)
+	a, b := parents(20)
+	red, blue := a[0].Color, b[0].Color
+
+	child := crossover(UniformCrossover, a, b)
+	fromB := 0
+	for i, polygon := range child {
+		if polygon.Color == blue {
+			fromB++
+			if polygon.Vertices[0] != b[i].Vertices[0] {
+				t.Fatalf("uniform: polygon %v mixes both parents", i)
+			}
+		}
+	}
+	if fromB == 0 || fromB == len(child) {
+		t.Errorf("uniform: %v of %v polygons from the second parent", fromB, len(child))
+	}
+
+	for k := 0; k < 20; k++ {
+		child = crossover(OnePointCrossover, a, b)
+		for i := 1; i < len(child); i++ {
+			if child[i-1].Color == blue && child[i].Color == red {
+				t.Fatalf("one-point: polygon %v comes back to the first parent", i)
+			}
+		}
+	}
+
+	child = crossover(PolygonCrossover, a, b)
+	mixed := false
+	for i, polygon := range child {
+		fromB := 0
+		for j, v := range polygon.Vertices {
+			switch v {
+			case a[i].Vertices[j]:
+			case b[i].Vertices[j]:
+				fromB++
+			default:
+				t.Fatalf("per-polygon: vertex %v of polygon %v from neither parent", j, i)
+			}
+		}
+		mixed = mixed || fromB > 0 && fromB < len(polygon.Vertices)
+	}
+	if !mixed {
+		t.Error("per-polygon: no polygon mixes the vertices of both parents")
+	}
+
+	if a[0].Color != red || b[0].Color != blue || a[0].Vertices[0] != (Point{0, 0}) {
+		t.Error("crossover modified the parents")
+	}
+}
+
+func TestSelectParent(t *testing.T) {
+	scores := []float64{1, 2, 3, 4}
+	counts := func(selection SelectionKind, scale float64) []int {
+		rand.Seed(9)
+		model := Model{Population: &Population{Selection: selection, TournamentSize: 3}}
+		population := make([]candidate, len(scores))
+		for i, s := range scores {
+			population[i] = candidate{score: s * scale}
+		}
+		picked := make([]int, len(scores))
+		for i := 0; i < 4000; i++ {
+			picked[int(model.selectParent(population).score/scale)-1]++
+		}
+		return picked
+	}
+
+	roulette := counts(RouletteSelection, 1)
+	if roulette[3] != 0 || roulette[0] <= roulette[1] || roulette[1] <= roulette[2] {
+		t.Errorf("roulette picked %v, want more of the better individuals and none of the worst", roulette)
+	}
+	// the weights only depend on the scores relative to their range
+	if scaled := counts(RouletteSelection, 1000); scaled[0] != roulette[0] || scaled[3] != roulette[3] {
+		t.Errorf("roulette picked %v with scores 1000 times larger, %v before", scaled, roulette)
+	}
+	if tournament := counts(TournamentSelection, 1); tournament[0] <= tournament[3] {
+		t.Errorf("tournament picked %v, want more of the best individual", tournament)
+	}
+}
+
+func TestEvolve(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 10, 1, Color{255, 255, 255, 255})
+	initial := model.Score
+	for _, kind := range []CrossoverKind{UniformCrossover, OnePointCrossover, PolygonCrossover} {
+		p, err := NewPopulation(6, RouletteSelection, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model.Population = p
+		score := model.Optimize(20, 2, 0)
+		if score > initial {
+			t.Errorf("%v: population ended at %v, worse than %v", kind, score, initial)
+		}
+		if len(model.Polygons) != 10 {
+			t.Errorf("%v: model has %v polygons", kind, len(model.Polygons))
+		}
+		checkIncrementalScore(t, model, score)
+		initial = score
+	}
+}
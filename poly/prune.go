@@ -0,0 +1,26 @@
+package poly
+
+// Prune removes the polygons that barely contribute to the score, the ones
+// whose removal makes the score worse by at most threshold, such as fully
+// covered, nearly transparent or degenerate polygons. Polygons are tested
+// from the top one down and the score is updated after every removal. The
+// last polygon is always kept so the model can still be optimized. It
+// returns the number of removed polygons. NumPolygons is kept, so the freed
+// slots can be filled again with OptimizeGreedy.
+func (m *Model) Prune(threshold float64) int {
+	if m.canvas == nil {
+		m.prepare()
+	}
+	removed := 0
+	for i := len(m.Polygons) - 1; i >= 0 && len(m.Polygons) > 1; i-- {
+		polygons := make(Polygons, 0, len(m.Polygons)-1)
+		polygons = append(polygons, m.Polygons[:i]...)
+		polygons = append(polygons, m.Polygons[i+1:]...)
+		c := m.scoreRegion(polygons, m.Polygons[i].bounds())
+		if c.score-m.Score <= threshold {
+			m.commit(c)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,44 @@
+package poly
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	target := Color{40, 90, 160, 255}
+	model := NewModel(uniformImage(32, 24, target), 6, 1, Color{255, 255, 255, 255})
+	cover := Polygon{
+		Color:    target,
+		Vertices: []Point{{-1, -1}, {40, -1}, {40, 30}, {-1, 30}},
+	}
+	degenerate := Polygon{
+		Color:    Color{R: 255, A: 255},
+		Vertices: []Point{{5, 5}, {10, 10}, {15, 15}},
+	}
+	model.Polygons = append(model.Polygons, cover, degenerate)
+	model.Rescore()
+	score := model.Score
+
+	removed := model.Prune(0)
+	if removed != 7 || len(model.Polygons) != 1 || model.Polygons[0].Color != target {
+		t.Fatalf("pruning removed %v polygons and left %v, want only the cover", removed, len(model.Polygons))
+	}
+	if model.Score != score {
+		t.Errorf("pruning changed the score from %v to %v", score, model.Score)
+	}
+	if model.NumPolygons != 6 {
+		t.Errorf("pruning changed NumPolygons to %v", model.NumPolygons)
+	}
+}
+
+func TestPruneKeepsLastPolygon(t *testing.T) {
+	model := NewModel(decodeTestImage(t), 5, 1, Color{255, 255, 255, 255})
+	if removed := model.Prune(math.Inf(1)); removed != 4 || len(model.Polygons) != 1 {
+		t.Fatalf("pruning removed %v polygons and left %v, want 1 left", removed, len(model.Polygons))
+	}
+	model.Optimize(10, 1, 0)
+	if len(model.Polygons) != 1 {
+		t.Errorf("optimized model has %v polygons", len(model.Polygons))
+	}
+}
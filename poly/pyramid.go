@@ -0,0 +1,140 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+)
+
+// minLevelSize is the smallest side of a pyramid level. Smaller levels
+// collapse the vertices of every polygon onto a few pixels.
+const minLevelSize = 8
+
+// PyramidLevels returns the number of pyramid levels of a width x height
+// image, including the full size one, keeping every level at least
+// minLevelSize pixels on both sides.
+func PyramidLevels(width, height int) int {
+	levels := 1
+	for width >= 2*minLevelSize && height >= 2*minLevelSize {
+		width, height = (width+1)/2, (height+1)/2
+		levels++
+	}
+	return levels
+}
+
+// OptimizePyramid optimizes the model from a coarse version of the target
+// image to the full resolution one. schedule holds the number of iterations
+// of every level, from the coarsest to the finest, every level having half
+// the size of the next one. Scale tracks the size of the current level
+// relative to the full resolution, and the vertices are scaled up when moving
+// to the next level. Levels coarser than PyramidLevels allows are merged
+// into the coarsest allowed one.
+func (m *Model) OptimizePyramid(schedule []int, concurrency, logFrequency int) float64 {
+	if len(schedule) == 0 {
+		return m.Score
+	}
+	if n := PyramidLevels(m.Width, m.Height); len(schedule) > n {
+		merged := append([]int{0}, schedule[len(schedule)-n+1:]...)
+		for _, iterations := range schedule[:len(schedule)-n+1] {
+			merged[0] += iterations
+		}
+		schedule = merged
+	}
+	targets := []*image.RGBA{m.TargetImage}
+	masks := []*image.Gray{m.Mask}
+	for len(targets) < len(schedule) {
+		targets = append(targets, downsampleRGBA(targets[len(targets)-1]))
+		if m.Mask != nil {
+			masks = append(masks, downsampleGray(masks[len(masks)-1]))
+		} else {
+			masks = append(masks, nil)
+		}
+	}
+
+	for i, iterations := range schedule {
+		level := len(schedule) - 1 - i
+		m.setLevel(targets[level], masks[level], 1/float64(int(1)<<level))
+		fmt.Printf("level %v: %vx%v\n", level, m.Width, m.Height)
+		m.Optimize(iterations, concurrency, logFrequency)
+	}
+	return m.Score
+}
+
+// setLevel replaces the target image by a scaled version of it and scales
+// the polygons accordingly.
+func (m *Model) setLevel(target *image.RGBA, mask *image.Gray, scale float64) {
+	factor := scale
+	if m.Scale > 0 {
+		factor /= m.Scale
+	}
+	m.TargetImage = target
+	m.Mask = mask
+	m.Width = target.Rect.Dx()
+	m.Height = target.Rect.Dy()
+	m.Scale = scale
+	polygons := m.Polygons.clone()
+	for _, polygon := range polygons {
+		for j, vertex := range polygon.Vertices {
+			polygon.Vertices[j] = Point{
+				X: scaleCoordinate(vertex.X, factor, m.Width),
+				Y: scaleCoordinate(vertex.Y, factor, m.Height),
+			}
+		}
+	}
+	m.Polygons = polygons
+	m.metric = nil
+	m.reportMetric = nil
+	m.sampler = nil
+	m.prepare()
+}
+
+// scaleCoordinate scales c by factor around the center of the first pixel,
+// keeping it inside [0, max-1].
+func scaleCoordinate(c, factor float64, max int) float64 {
+	return clampFloat((c+0.5)*factor-0.5, 0, float64(max-1))
+}
+
+// downsampleRGBA returns the image at half its size, every pixel being the
+// average of a 2x2 square.
+func downsampleRGBA(src *image.RGBA) *image.RGBA {
+	b := src.Rect
+	dst := image.NewRGBA(image.Rect(0, 0, (b.Dx()+1)/2, (b.Dy()+1)/2))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			cell := image.Rect(b.Min.X+2*x, b.Min.Y+2*y, b.Min.X+2*x+2, b.Min.Y+2*y+2).Intersect(b)
+			var sum [4]int
+			for cy := cell.Min.Y; cy < cell.Max.Y; cy++ {
+				for cx := cell.Min.X; cx < cell.Max.X; cx++ {
+					p := src.PixOffset(cx, cy)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[p+c])
+					}
+				}
+			}
+			n := cell.Dx() * cell.Dy()
+			p := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[p+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+func downsampleGray(src *image.Gray) *image.Gray {
+	b := src.Rect
+	dst := image.NewGray(image.Rect(0, 0, (b.Dx()+1)/2, (b.Dy()+1)/2))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			cell := image.Rect(b.Min.X+2*x, b.Min.Y+2*y, b.Min.X+2*x+2, b.Min.Y+2*y+2).Intersect(b)
+			sum := 0
+			for cy := cell.Min.Y; cy < cell.Max.Y; cy++ {
+				for cx := cell.Min.X; cx < cell.Max.X; cx++ {
+					sum += int(src.Pix[src.PixOffset(cx, cy)])
+				}
+			}
+			n := cell.Dx() * cell.Dy()
+			dst.Pix[dst.PixOffset(x, y)] = uint8((sum + n/2) / n)
+		}
+	}
+	return dst
+}
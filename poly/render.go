@@ -0,0 +1,139 @@
+package poly
+
+import (
+	"image"
+	"math"
+)
+
+// bounds returns the smallest rectangle containing every pixel the polygon
+// can paint. A pixel spans half a pixel around its center, which the
+// anti-aliased rasterizer can cover even when the center is outside.
+func (p Polygon) bounds() image.Rectangle {
+	if len(p.Vertices) == 0 {
+		return image.Rectangle{}
+	}
+	minX, maxX, minY, maxY := minMaxPoints(p.Vertices)
+	return image.Rect(
+		int(math.Floor(minX-0.5)), int(math.Floor(minY-0.5)),
+		int(math.Floor(maxX+0.5))+1, int(math.Floor(maxY+0.5))+1,
+	)
+}
+
+// renderRegion paints only the pixels of the polygons inside r. The returned
+// image has r as its bounds, so it can be compared with the full size target
+// using the same coordinates.
+func renderRegion(polygons Polygons, bgColor Color, rule FillRule, antialias bool, r image.Rectangle) *image.RGBA {
+	rgba := image.NewRGBA(r)
+	l := len(rgba.Pix)
+	for i := 0; i < l; i += 4 {
+		rgba.Pix[i] = bgColor.R
+		rgba.Pix[i+1] = bgColor.G
+		rgba.Pix[i+2] = bgColor.B
+		rgba.Pix[i+3] = bgColor.A
+	}
+	if antialias {
+		renderAA(polygons, rule, rgba)
+		return rgba
+	}
+	for _, polygon := range polygons {
+		if polygon.bounds().Overlaps(r) {
+			rasterizePolygon(polygon, rule, rgba)
+		}
+	}
+	return rgba
+}
+
+// copyRegion copies the pixels of src into dst. src bounds must be inside
+// dst bounds.
+func copyRegion(dst, src *image.RGBA) {
+	r := src.Rect
+	width := 4 * r.Dx()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		d := dst.PixOffset(r.Min.X, y)
+		s := src.PixOffset(r.Min.X, y)
+		copy(dst.Pix[d:d+width], src.Pix[s:s+width])
+	}
+}
+
+// cropRGBA returns a copy of the pixels of src inside r.
+func cropRGBA(src *image.RGBA, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(r.Intersect(src.Rect))
+	copyRegion(dst, src.SubImage(dst.Rect).(*image.RGBA))
+	return dst
+}
+
+// prepare renders the current polygons into the canvas used to score
+// candidates incrementally.
+func (m *Model) prepare() {
+	if m.metric == nil {
+		metric, err := NewMetric(m.Metric, m.TargetImage, m.Mask)
+		if err != nil {
+			panic(err)
+		}
+		m.metric = metric
+	}
+	if m.sampler == nil && m.Importance != "" {
+		m.sampler = newSampler(m.Mask)
+	}
+	m.canvas = polygonsToRGBA(m.Polygons, m.BackgroundColor, m.FillRule, m.AntiAlias, m.Width, m.Height)
+	m.err = m.metric.Error(m.canvas, m.canvas.Rect)
+	m.Score = m.metric.Score(m.err)
+}
+
+// scoreRegion scores polygons that only differ from the model polygons inside
+// the dirty rectangle. Only that region is rendered and compared with the
+// target, the error of the rest of the image is taken from the model.
+func (m *Model) scoreRegion(polygons Polygons, dirty image.Rectangle) candidate {
+	dirty = dirty.Intersect(m.canvas.Rect)
+	region := renderRegion(polygons, m.BackgroundColor, m.FillRule, m.AntiAlias, dirty)
+	if a, ok := m.metric.(aligner); ok {
+		// the pixels around the dirty region did not change, so they are
+		// copied from the canvas instead of rendered
+		aligned := cropRGBA(m.canvas, a.align(dirty))
+		copyRegion(aligned, region)
+		region, dirty = aligned, aligned.Rect
+	}
+	err := m.err - m.metric.Error(m.canvas, dirty) + m.metric.Error(region, dirty)
+	return candidate{
+		polygons: polygons,
+		score:    m.metric.Score(err),
+		err:      err,
+		region:   region,
+	}
+}
+
+// commit replaces the model polygons with the candidate ones. Candidates
+// scored incrementally patch the canvas, the rest invalidate it.
+func (m *Model) commit(c candidate) {
+	m.Polygons = c.polygons
+	m.Score = c.score
+	if c.region == nil || m.canvas == nil {
+		m.canvas = nil
+		return
+	}
+	copyRegion(m.canvas, c.region)
+	m.err = c.err
+}
+
+// RenderAt rasterizes the polygons with anti-aliasing at any resolution. The
+// vertices are scaled independently on each axis so the image can have a
+// different aspect ratio than the model.
+func (m *Model) RenderAt(width, height int) *image.RGBA {
+	if width == m.Width && height == m.Height {
+		return polygonsToRGBA(m.Polygons, m.BackgroundColor, m.FillRule, true, m.Width, m.Height)
+	}
+	sx := float64(width) / float64(m.Width)
+	sy := float64(height) / float64(m.Height)
+	polygons := m.Polygons.clone()
+	for _, polygon := range polygons {
+		for i, vertex := range polygon.Vertices {
+			// pixels are sampled at their centers, so the scaling is done
+			// around the center of the first pixel
+			polygon.Vertices[i] = Point{
+				X: (vertex.X+0.5)*sx - 0.5,
+				Y: (vertex.Y+0.5)*sy - 0.5,
+			}
+		}
+	}
+	return polygonsToRGBA(polygons, m.BackgroundColor, m.FillRule, true, width, height)
+}
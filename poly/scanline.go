@@ -0,0 +1,136 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// FillRule decides which pixels are inside a self-intersecting polygon.
+type FillRule int
+
+const (
+	// NonZero fills every pixel with a winding number different from zero
+	NonZero FillRule = iota
+	// EvenOdd fills every pixel enclosed by an odd number of edges
+	EvenOdd
+)
+
+func (r FillRule) inside(winding int) bool {
+	if r == EvenOdd {
+		return winding%2 != 0
+	}
+	return winding != 0
+}
+
+// edge is a polygon edge with y0 < y1. dir is +1 for edges going down in
+// the polygon order (increasing y) and -1 for the others.
+type edge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+// crossing is the first pixel of a scanline to the right of an edge.
+type crossing struct {
+	x, dir int
+}
+
+// crossingX returns the smallest integer x such that the point (x, y) is not
+// strictly to the left of the edge, which is where windingNumber stops
+// counting the edge. The numerator is computed before dividing so that edges
+// with integer vertices cross rows exactly.
+func (e edge) crossingX(y int) int {
+	dy := e.y1 - e.y0
+	num := e.x0*dy + (e.x1-e.x0)*(float64(y)-e.y0)
+	return int(math.Ceil(num / dy))
+}
+
+// newEdgeTable returns the non horizontal edges of the polygon sorted by
+// their top coordinate.
+func newEdgeTable(vertices []Point) []edge {
+	edges := make([]edge, 0, len(vertices))
+	for i := range vertices {
+		a, b := vertices[i], vertices[(i+1)%len(vertices)]
+		switch {
+		case a.Y < b.Y:
+			edges = append(edges, edge{a.X, a.Y, b.X, b.Y, 1})
+		case a.Y > b.Y:
+			edges = append(edges, edge{b.X, b.Y, a.X, a.Y, -1})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].y0 < edges[j].y0
+	})
+	return edges
+}
+
+// rasterizePolygon paints the polygon on the canvas with an active edge table
+// scanline fill. Pixels are sampled at their integer coordinates, so with the
+// NonZero rule it paints exactly the same pixels as rasterizePolygonWWN.
+// Pixels outside the canvas bounds are skipped.
+func rasterizePolygon(polygon Polygon, rule FillRule, canvas *image.RGBA) {
+	if len(polygon.Vertices) < 3 {
+		return
+	}
+	edges := newEdgeTable(polygon.Vertices)
+	if len(edges) == 0 {
+		return
+	}
+	bounds := canvas.Rect
+	// rows are sampled at integer y, the first one not above the top edge
+	minY := int(math.Ceil(edges[0].y0))
+	if minY < bounds.Min.Y {
+		minY = bounds.Min.Y
+	}
+	maxY := edges[0].y1
+	for _, e := range edges {
+		maxY = math.Max(maxY, e.y1)
+	}
+	endY := int(math.Ceil(maxY))
+	if endY > bounds.Max.Y {
+		endY = bounds.Max.Y
+	}
+
+	var active []edge
+	var crossings []crossing
+	next := 0
+	for y := minY; y < endY; y++ {
+		// edges are active on the half open interval [y0, y1)
+		for next < len(edges) && edges[next].y0 <= float64(y) {
+			active = append(active, edges[next])
+			next++
+		}
+		n := 0
+		for _, e := range active {
+			if e.y1 > float64(y) {
+				active[n] = e
+				n++
+			}
+		}
+		active = active[:n]
+
+		crossings = crossings[:0]
+		for _, e := range active {
+			crossings = append(crossings, crossing{e.crossingX(y), e.dir})
+		}
+		sort.Slice(crossings, func(i, j int) bool {
+			return crossings[i].x < crossings[j].x
+		})
+
+		winding := 0
+		for i := 0; i < len(crossings)-1; i++ {
+			winding += crossings[i].dir
+			if !rule.inside(winding) {
+				continue
+			}
+			x0, x1 := crossings[i].x, crossings[i+1].x
+			if x0 < bounds.Min.X {
+				x0 = bounds.Min.X
+			}
+			if x1 > bounds.Max.X {
+				x1 = bounds.Max.X
+			}
+			fillRow(y, x0, x1, polygon.Color, canvas)
+		}
+	}
+}
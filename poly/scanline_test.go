@@ -0,0 +1,142 @@
+package poly
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func blankCanvas(w, h int) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range canvas.Pix {
+		canvas.Pix[i] = 0xff
+	}
+	return canvas
+}
+
+func TestRasterizePolygonMatchesWindingNumber(t *testing.T) {
+	rand.Seed(42)
+	w, h := 64, 48
+	for i := 0; i < 500; i++ {
+		order := rand.Intn(6) + 3
+		polygon := newRandomPolygon(order, w, h, nil)
+		polygon.Color = Color{R: 200, G: 20, B: 90, A: 128}
+
+		want := blankCanvas(w, h)
+		rasterizePolygonWWN(polygon, want)
+		got := blankCanvas(w, h)
+		rasterizePolygon(polygon, NonZero, got)
+
+		if !bytes.Equal(want.Pix, got.Pix) {
+			t.Fatalf("polygon %v: scanline and winding number fills differ", polygon.Vertices)
+		}
+	}
+}
+
+func TestRasterizePolygonEvenOdd(t *testing.T) {
+	// a pentagram, its center has a winding number of 2
+	polygon := Polygon{
+		Color:    Color{A: 255},
+		Vertices: []Point{{20, 0}, {32, 38}, {0, 14}, {40, 14}, {8, 38}},
+	}
+	nonZero := blankCanvas(41, 40)
+	rasterizePolygon(polygon, NonZero, nonZero)
+	evenOdd := blankCanvas(41, 40)
+	rasterizePolygon(polygon, EvenOdd, evenOdd)
+
+	center := nonZero.PixOffset(20, 20)
+	if nonZero.Pix[center] != 0 {
+		t.Errorf("non-zero rule should fill the center of the pentagram")
+	}
+	if evenOdd.Pix[center] != 0xff {
+		t.Errorf("even-odd rule should not fill the center of the pentagram")
+	}
+	tip := nonZero.PixOffset(20, 3)
+	if nonZero.Pix[tip] != 0 || evenOdd.Pix[tip] != 0 {
+		t.Errorf("both rules should fill the tips of the pentagram")
+	}
+}
+
+func TestAntiAliasedSharedEdge(t *testing.T) {
+	// two opaque black triangles splitting a square along its diagonal
+	black := Color{A: 255}
+	polygons := Polygons{
+		{Color: black, Vertices: []Point{{2, 2}, {28, 2}, {28, 20}}},
+		{Color: black, Vertices: []Point{{2, 2}, {28, 20}, {2, 20}}},
+	}
+	magenta := Color{255, 0, 255, 255}
+	for _, scale := range []int{1, 3} {
+		model := Model{Width: 32, Height: 24, Polygons: polygons, BackgroundColor: magenta}
+		rgba := model.RenderAt(32*scale, 24*scale)
+		// the pixels whose centers are inside the square
+		for y := 2*scale + scale; y < 20*scale; y++ {
+			for x := 2*scale + scale; x < 28*scale; x++ {
+				if p := rgba.PixOffset(x, y); rgba.Pix[p] != 0 || rgba.Pix[p+2] != 0 {
+					t.Fatalf("scale %v: pixel %v,%v shows the background: %v", scale, x, y, rgba.Pix[p:p+4])
+				}
+			}
+		}
+	}
+}
+
+func TestRasterizePolygonAA(t *testing.T) {
+	polygon := Polygon{
+		Color:    Color{A: 255},
+		Vertices: []Point{{2, 2}, {30, 5}, {12, 28}},
+	}
+	binary := blankCanvas(32, 32)
+	rasterizePolygon(polygon, NonZero, binary)
+	aa := blankCanvas(32, 32)
+	rasterizePolygonAA(polygon, NonZero, aa)
+
+	var aaInk, partial int
+	for i := 0; i < len(aa.Pix); i += 4 {
+		aaInk += 255 - int(aa.Pix[i])
+		if aa.Pix[i] != 0 && aa.Pix[i] != 0xff {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Errorf("anti-aliased edges should have partially covered pixels")
+	}
+	// the coverage estimates the area of the triangle, 349 pixels
+	if d := float64(aaInk)/255 - 349; d > 10 || d < -10 {
+		t.Errorf("anti-aliased coverage %v, want about 349", float64(aaInk)/255)
+	}
+	center := aa.PixOffset(14, 12)
+	if aa.Pix[center] != binary.Pix[center] {
+		t.Errorf("fully covered pixels should match the binary rasterizer")
+	}
+}
+
+func benchmarkPolygons(n, w, h int) Polygons {
+	rand.Seed(1)
+	var polygons Polygons
+	for i := 0; i < n; i++ {
+		polygons = append(polygons, newRandomPolygon(rand.Intn(3)+3, w, h, nil))
+	}
+	return polygons
+}
+
+func BenchmarkRasterizePolygon(b *testing.B) {
+	polygons := benchmarkPolygons(50, 256, 256)
+	canvas := blankCanvas(256, 256)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, polygon := range polygons {
+			rasterizePolygon(polygon, NonZero, canvas)
+		}
+	}
+}
+
+func BenchmarkRasterizePolygonWWN(b *testing.B) {
+	polygons := benchmarkPolygons(50, 256, 256)
+	canvas := blankCanvas(256, 256)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, polygon := range polygons {
+			rasterizePolygonWWN(polygon, canvas)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package poly
+
+import "image"
+
+// The SSIM metrics compare non overlapping blocks instead of a sliding
+// window, and BlockMSSSIMMetric averages its scales instead of multiplying
+// them, so they approximate SSIM and MS-SSIM while keeping the error of a
+// region independent of the rest of the image.
+const (
+	BlockSSIMMetric   MetricKind = "block-ssim"
+	BlockMSSSIMMetric MetricKind = "block-ms-ssim"
+)
+
+const (
+	// ssimBlock is the side in pixels of the windows compared by SSIM
+	ssimBlock = 8
+	ssimC1    = (0.01 * 255) * (0.01 * 255)
+	ssimC2    = (0.03 * 255) * (0.03 * 255)
+)
+
+// msssimWeights are the weights of every scale of MS-SSIM, from the
+// original resolution to the coarsest one.
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+func init() {
+	metrics[BlockSSIMMetric] = func(target *image.RGBA, w weights) Metric {
+		return newSSIMMetric(target, w, []float64{1})
+	}
+	metrics[BlockMSSSIMMetric] = func(target *image.RGBA, w weights) Metric {
+		return newSSIMMetric(target, w, msssimWeights)
+	}
+}
+
+// ssimMetric is the structural dissimilarity 1 - SSIM. SSIM is computed on a
+// grid of non overlapping windows, so the error of a region is the sum of
+// the errors of the windows touching it. For MS-SSIM every coarser scale
+// averages 2x2 cells of the previous one, and the per scale errors are
+// combined as a weighted mean instead of the usual product so the error
+// stays additive. With a mask every window is weighted by its mean weight.
+type ssimMetric struct {
+	target  *image.RGBA
+	mask    weights
+	weights []float64
+	// totals are the sum of the window weights of every scale
+	totals []float64
+}
+
+func newSSIMMetric(target *image.RGBA, mask weights, scales []float64) ssimMetric {
+	s := ssimMetric{target: target, mask: mask, weights: scales}
+	bounds := target.Rect
+	for scale := range scales {
+		size := ssimBlock << scale
+		var total float64
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += size {
+			for x := bounds.Min.X; x < bounds.Max.X; x += size {
+				total += s.blockWeight(image.Rect(x, y, x+size, y+size).Intersect(bounds))
+			}
+		}
+		s.totals = append(s.totals, total)
+	}
+	return s
+}
+
+// blockWeight returns the mean weight of the pixels of the block.
+func (s ssimMetric) blockWeight(block image.Rectangle) float64 {
+	return float64(s.mask.total(block)) / float64(255*block.Dx()*block.Dy())
+}
+
+func (s ssimMetric) Error(candidate *image.RGBA, r image.Rectangle) float64 {
+	bounds := s.target.Rect
+	var total, err float64
+	for scale, weight := range s.weights {
+		total += weight
+		cell := 1 << scale
+		size := ssimBlock * cell
+		var sum float64
+		for y := r.Min.Y / size * size; y < r.Max.Y; y += size {
+			for x := r.Min.X / size * size; x < r.Max.X; x += size {
+				block := image.Rect(x, y, x+size, y+size).Intersect(bounds)
+				if s.mask.mask == nil {
+					sum += 1 - s.ssim(candidate, block, cell)
+				} else if w := s.blockWeight(block); w > 0 {
+					sum += w * (1 - s.ssim(candidate, block, cell))
+				}
+			}
+		}
+		if s.totals[scale] > 0 {
+			err += weight * sum / s.totals[scale]
+		}
+	}
+	return err / total
+}
+
+func (s ssimMetric) Score(err float64) float64 {
+	return err
+}
+
+// align expands r to the windows of the coarsest scale touching it, which
+// are the pixels Error reads.
+func (s ssimMetric) align(r image.Rectangle) image.Rectangle {
+	size := ssimBlock << (len(s.weights) - 1)
+	r.Min.X = r.Min.X / size * size
+	r.Min.Y = r.Min.Y / size * size
+	r.Max.X = (r.Max.X + size - 1) / size * size
+	r.Max.Y = (r.Max.Y + size - 1) / size * size
+	return r.Intersect(s.target.Rect)
+}
+
+// ssim returns the mean SSIM of the color channels inside the block, after
+// averaging the pixels of every cell x cell square.
+func (s ssimMetric) ssim(candidate *image.RGBA, block image.Rectangle, cell int) float64 {
+	var sumX, sumY, sumXX, sumYY, sumXY [3]float64
+	var n float64
+	for y := block.Min.Y; y < block.Max.Y; y += cell {
+		for x := block.Min.X; x < block.Max.X; x += cell {
+			c := image.Rect(x, y, x+cell, y+cell).Intersect(block)
+			tx := cellMean(s.target, c)
+			cx := cellMean(candidate, c)
+			for i := 0; i < 3; i++ {
+				sumX[i] += tx[i]
+				sumY[i] += cx[i]
+				sumXX[i] += tx[i] * tx[i]
+				sumYY[i] += cx[i] * cx[i]
+				sumXY[i] += tx[i] * cx[i]
+			}
+			n++
+		}
+	}
+	var ssim float64
+	for i := 0; i < 3; i++ {
+		muX, muY := sumX[i]/n, sumY[i]/n
+		varX := sumXX[i]/n - muX*muX
+		varY := sumYY[i]/n - muY*muY
+		cov := sumXY[i]/n - muX*muY
+		ssim += ((2*muX*muY + ssimC1) * (2*cov + ssimC2)) /
+			((muX*muX + muY*muY + ssimC1) * (varX + varY + ssimC2))
+	}
+	return ssim / 3
+}
+
+// cellMean returns the mean of every color channel inside r.
+func cellMean(rgba *image.RGBA, r image.Rectangle) [3]float64 {
+	var sum [3]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		p := rgba.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum[0] += int(rgba.Pix[p])
+			sum[1] += int(rgba.Pix[p+1])
+			sum[2] += int(rgba.Pix[p+2])
+			p += 4
+		}
+	}
+	n := float64(r.Dx() * r.Dy())
+	return [3]float64{float64(sum[0]) / n, float64(sum[1]) / n, float64(sum[2]) / n}
+}
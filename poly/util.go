@@ -5,17 +5,17 @@ import (
 	"fmt"
 	"image"
 	"log"
-	"math"
 
 	// Decoding jpg images
 	"image/draw"
 	_ "image/jpeg"
 	"os"
+	"sync"
 )
 
 func PrintDefaultsWithError(errorMessage string) {
 	log.Printf("invalid input parameters: %v", errorMessage)
-	fmt.Println("Usage: poly [OPTIONS] -o output")
+	fmt.Println("Usage: poly [prune] [OPTIONS] -o output")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
@@ -37,7 +37,7 @@ func imageToRGBA(src image.Image) *image.RGBA {
 }
 
 // minMaxPoints returns the boundaries of the smallest rectangle that contains all the given points
-func minMaxPoints(points []Point) (int, int, int, int) {
+func minMaxPoints(points []Point) (float64, float64, float64, float64) {
 	xmin, xmax, ymin, ymax := points[0].X, points[0].X, points[0].Y, points[0].Y
 	for _, point := range points {
 		if point.X > xmax {
@@ -66,38 +66,33 @@ func SaveFile(path, contents string) error {
 	return err
 }
 
-// TODO: Could do it faster assuming a, b > 0
-func pow(a, b int) int {
-	p := 1
-	for b > 0 {
-		if b&1 != 0 {
-			p *= a
-		}
-		b >>= 1
-		a *= a
-	}
-	return p
-}
-
-func mse(target, candidate *image.RGBA) float64 {
-	targetPixels := target.Pix
-	w, h := candidate.Bounds().Max.X, candidate.Bounds().Max.Y
-	size := w * h * 4
-	sum := 0
-	for i := 0; i < size; i++ {
-		if i%3 != 0 { // avoiding calculating difference for transparency pixels
-			d := absoluteDifferenceInt8(targetPixels[i], candidate.Pix[i])
-			// TODO: Write a faster Pow func
-			sum = sum + pow(d, 2)
-		}
-	}
-
-	return math.Sqrt(float64(sum))
-}
-
 func absoluteDifferenceInt8(a, b uint8) int {
 	if a > b {
 		return int(a - b)
 	}
 	return int(b - a)
 }
+
+// parallel calls f for every i in [0, n) using at most concurrency goroutines
+// and waits for all the calls to return.
+func parallel(n, concurrency int, f func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -1,9 +1,14 @@
 package poly
 
-import "image"
+import (
+	"image"
+	"math"
+)
 
+// Point is a position on the canvas. Coordinates are not bound to the pixel
+// grid, the center of the pixel (x, y) being at the integer coordinates.
 type Point struct {
-	X, Y int
+	X, Y float64
 }
 
 func (point *Point) clone() Point {
@@ -54,25 +59,16 @@ func windingNumber(P Point, V []Point) int {
 //            =0 for P2  on the line
 //            <0 for P2  right of the line
 //    See: Algorithm 1 "Area of Triangles and Polygons"
-func isLeft(P0, P1, P2 Point) int {
+func isLeft(P0, P1, P2 Point) float64 {
 	return (P1.X-P0.X)*(P2.Y-P0.Y) - (P2.X-P0.X)*(P1.Y-P0.Y)
 }
 
 func rasterizePolygonWWN(polygon Polygon, result *image.RGBA) {
 	minX, maxX, minY, maxY := minMaxPoints(polygon.Vertices)
-	// copy(polygon.subImage.Pix, result.Pix)
-	if polygon.HasPoints {
-		for _, point := range polygon.Points {
-			drawPoint(point.X, point.Y, polygon.Color, result)
-		}
-	} else {
-		polygon.HasPoints = true
-		for x := minX; x <= maxX; x++ {
-			for y := minY; y <= maxY; y++ {
-				if windingNumber(Point{x, y}, polygon.Vertices) != 0 {
-					drawPoint(x, y, polygon.Color, result)
-					polygon.Points = append(polygon.Points, Point{x, y})
-				}
+	for x := int(math.Ceil(minX)); x <= int(math.Floor(maxX)); x++ {
+		for y := int(math.Ceil(minY)); y <= int(math.Floor(maxY)); y++ {
+			if windingNumber(Point{float64(x), float64(y)}, polygon.Vertices) != 0 {
+				drawPoint(x, y, polygon.Color, result)
 			}
 		}
 	}
@@ -80,11 +76,9 @@ func rasterizePolygonWWN(polygon Polygon, result *image.RGBA) {
 
 func subtractPolygonWWN(polygon *Polygon, result *image.RGBA) {
 	minX, maxX, minY, maxY := minMaxPoints(polygon.Vertices)
-	var point Point
-	for x := minX; x <= maxX; x++ {
-		for y := minY; y <= maxY; y++ {
-			point = Point{x, y}
-			if windingNumber(point, polygon.Vertices) != 0 {
+	for x := int(math.Ceil(minX)); x <= int(math.Floor(maxX)); x++ {
+		for y := int(math.Ceil(minY)); y <= int(math.Floor(maxY)); y++ {
+			if windingNumber(Point{float64(x), float64(y)}, polygon.Vertices) != 0 {
 				subtractPoint(x, y, polygon.Color, result)
 			}
 		}